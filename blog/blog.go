@@ -1,6 +1,7 @@
 package blog
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io"
@@ -8,19 +9,26 @@ import (
 	"log"
 	"net/url"
 	"os"
-	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+)
+
+const (
+	bundleIndexName = "index"
+)
 
-	"github.com/gorilla/feeds"
+var (
+	postExts = []string{".md", ".markdown"}
 )
 
 type PageInfo struct {
 	PageName string
 	Blog     *Config
+	NoIndex  bool
 }
 
 type IndexInfo struct {
@@ -37,11 +45,48 @@ type Blog struct {
 	dir           string
 	templates     map[string]*template.Template
 	pageTemplates map[string]*template.Template
+	taxonomies    []*Taxonomy
+	location      *time.Location
+	cards         *cardRenderer
+	sitemap       *sitemap
+	output        *output
+	pages         []*queuedPage
+	queued        map[string]*queuedPage
+}
+
+type postSource struct {
+	name      string
+	filename  string
+	bundleDir string
 }
 
 type tmplRenderer struct {
 	log       func(format string, v ...interface{})
 	templates map[string]*template.Template
+	// file maps the names of templates to the files they were loaded from
+	file   func(name string) string
+	output *output
+}
+
+type queuedPage struct {
+	renderer *tmplRenderer
+	filename string
+	name     string
+	data     interface{}
+}
+
+// source describes what a queued page is rendered for, for error messages.
+func (p *queuedPage) source() string {
+	switch info := p.data.(type) {
+	case *PostInfo:
+		return "post " + info.Post.Name
+	case *TermInfo:
+		return fmt.Sprintf("%s %q", info.Taxonomy.Name, info.Term.Name)
+	case *TermsInfo:
+		return "taxonomy " + info.Taxonomy.Name
+	}
+
+	return "page " + p.name
 }
 
 func New(config Config, dir string, logger *log.Logger) (*Blog, error) {
@@ -51,11 +96,33 @@ func New(config Config, dir string, logger *log.Logger) (*Blog, error) {
 		dir:    dir,
 	}
 
+	switch config.FrontMatter {
+	case "", FrontMatterStandard, FrontMatterCodeBlock:
+	default:
+		return nil, fmt.Errorf("unknown front matter mode: %s", config.FrontMatter)
+	}
+
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	b.location = location
+
+	if err := b.loadTaxonomies(); err != nil {
+		return nil, err
+	}
+
 	themeDir := filepath.Join(dir, "theme")
 	if err := b.loadTheme(themeDir); err != nil {
 		return nil, err
 	}
 
+	if b.hasFeature("social_cards") {
+		if err := b.loadCardRenderer(); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := b.loadTemplates(filepath.Join(themeDir, "templates")); err != nil {
 		return nil, err
 	}
@@ -63,27 +130,51 @@ func New(config Config, dir string, logger *log.Logger) (*Blog, error) {
 	return &b, nil
 }
 
+// Generate generates the blog into the given directory. The blog is generated
+// in a staging directory next to it first, which is swapped into place once
+// generation succeeds. The previous output is left intact on failure.
 func (b *Blog) Generate(dir string) error {
 	posts, err := b.renderPosts()
 	if err != nil {
 		return err
 	}
 
-	// create the directory if needed
-	if err = mkdir(dir); err != nil {
+	if dir, err = filepath.Abs(dir); err != nil {
+		return err
+	}
+	stagingDir := siblingDir(dir, "staging")
+	if err = os.RemoveAll(stagingDir); err != nil {
 		return err
 	}
-	// clear the directory
-	if err = clearDir(dir); err != nil {
+	defer os.RemoveAll(stagingDir)
+
+	// start from the previous output so that unchanged files aren't rewritten
+	if _, err = os.Stat(dir); err == nil {
+		err = linkDir(stagingDir, dir)
+	} else if os.IsNotExist(err) {
+		err = os.MkdirAll(stagingDir, 0777)
+	}
+	if err != nil {
 		return err
 	}
 
+	if err = b.generate(stagingDir, posts); err != nil {
+		return err
+	}
+
+	return swapDir(dir, stagingDir)
+}
+
+func (b *Blog) generate(dir string, posts []*Post) error {
+	var err error
+	b.output = newOutput(dir)
+
 	// copy extra files/folders over
 	for _, path := range b.config.Files {
 		dst := filepath.Join(dir, path)
 		src := filepath.Join(b.dir, path)
 
-		if err := copyFileOrDir(dst, src); err != nil {
+		if err := b.output.copyFileOrDir(dst, src); err != nil {
 			return err
 		}
 	}
@@ -93,151 +184,383 @@ func (b *Blog) Generate(dir string) error {
 	if err = mkdir(staticDir); err != nil {
 		return err
 	}
-	if err = b.theme.Generate(staticDir); err != nil {
+	if err = b.theme.Generate(b.output, staticDir); err != nil {
 		return err
 	}
 
+	b.pages = nil
+	b.queued = map[string]*queuedPage{}
+	b.sitemap = nil
+	if b.hasFeature("sitemap") {
+		b.sitemap = newSitemap(dir, b.config.URL)
+	}
+
 	// generate index and pagination
-	cmpntRenderer := b.newRenderer(b.templates)
+	cmpntRenderer := b.newRenderer(b.templates, "components")
 	if b.config.PageSize < 1 {
 		return fmt.Errorf("invalid page size: %d", b.config.PageSize)
 	}
-	pagesDir := filepath.Join(dir, "page")
-	if err = mkdir(pagesDir); err != nil {
+	// unlisted and expired posts are only reachable through their own URL
+	listed := listedPosts(posts)
+	err = b.renderIndex(cmpntRenderer, dir, "index.html", listed, func(info *IndexInfo) interface{} {
+		return info
+	})
+	if err != nil {
 		return err
 	}
-	totalPages := (len(posts)-1)/b.config.PageSize + 1
-	for i := 0; i < totalPages; i++ {
-		info := IndexInfo{
-			PageInfo:   &PageInfo{PageName: "index.html", Blog: &b.config},
-			Posts:      posts[i*b.config.PageSize : min(i*b.config.PageSize+b.config.PageSize, len(posts))],
-			Page:       i + 1,
-			TotalPages: totalPages,
+
+	// generate the post pages
+	for _, post := range posts {
+		pageName := "post.html"
+		if post.Expired {
+			pageName = "expired.html"
 		}
 
-		if info.Page == 1 {
-			err = cmpntRenderer.renderPage(filepath.Join(dir, "index.html"), "index.html", &info)
-		} else {
-			pageDir := filepath.Join(pagesDir, strconv.Itoa(info.Page))
-			if err = mkdir(pageDir); err != nil {
+		filename := urlToFilename(dir, post.URL)
+		if err = os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			return err
+		}
+
+		info := PostInfo{
+			PageInfo: &PageInfo{PageName: pageName, Blog: &b.config, NoIndex: post.Unlisted || post.Expired},
+			Post:     post,
+		}
+		if err = b.queuePage(cmpntRenderer, filename, pageName, &info); err != nil {
+			return err
+		}
+
+		if post.bundleDir != "" && !post.Expired {
+			if err = b.copyBundleAssets(dir, post); err != nil {
 				return err
 			}
+		}
+	}
 
-			err = cmpntRenderer.renderPage(filepath.Join(pageDir, "index.html"), "index.html", &info)
+	// generate social cards for posts without a cover image
+	if b.cards != nil {
+		if err = b.renderCards(dir, posts); err != nil {
+			return err
 		}
+	}
 
-		if err != nil {
+	// generate the custom pages
+	pageRenderer := b.newRenderer(b.pageTemplates, "pages")
+	for name := range b.pageTemplates {
+		info := PageInfo{PageName: name, Blog: &b.config}
+		if err = b.queuePage(pageRenderer, filepath.Join(dir, name), name, &info); err != nil {
 			return err
 		}
 	}
 
-	// generate the post pages
-	postDir := filepath.Join(dir, "post")
-	if err = mkdir(postDir); err != nil {
+	// generate the taxonomy pages
+	for _, taxonomy := range b.taxonomies {
+		if err = b.renderTaxonomy(cmpntRenderer, dir, taxonomy, listed); err != nil {
+			return err
+		}
+	}
+
+	// render all of the queued pages
+	if err = b.renderQueuedPages(); err != nil {
 		return err
 	}
-	for _, post := range posts {
-		const pageName = "post.html"
-		info := PostInfo{PageInfo: &PageInfo{PageName: pageName, Blog: &b.config}, Post: post}
-		if err = cmpntRenderer.renderPage(filepath.Join(postDir, post.Filename), pageName, &info); err != nil {
+
+	// generate feeds
+	if b.hasFeeds() {
+		feed, err := b.newFeed(b.config.Title, b.config.URL, listed)
+		if err != nil {
 			return err
 		}
-	}
 
-	// generate the custom pages
-	pageRenderer := b.newRenderer(b.pageTemplates)
-	for name := range b.pageTemplates {
-		info := PageInfo{PageName: name, Blog: &b.config}
-		if err = pageRenderer.renderPage(filepath.Join(dir, name), name, &info); err != nil {
+		if err = b.writeFeeds(dir, feed); err != nil {
 			return err
 		}
 	}
 
-	// generate rss feed
-	if b.hasFeature("rss") {
-		feed := &feeds.Feed{
-			Title:       b.config.Title,
-			Link:        &feeds.Link{Href: b.config.URL},
-			Description: b.config.Description,
+	// generate sitemap and robots.txt
+	if b.sitemap != nil {
+		data, err := b.sitemap.encode()
+		if err != nil {
+			return err
 		}
 
-		for _, post := range posts {
-			if post.Draft {
-				continue
-			}
+		if err = b.output.writeFile(filepath.Join(dir, sitemapFilename), data, 0666); err != nil {
+			return err
+		}
 
-			url, err := url.Parse(b.config.URL)
-			if err != nil {
-				return err
-			}
-			url.Path = path.Join(url.Path, "post", post.Filename)
+		if err = b.writeRobots(dir); err != nil {
+			return err
+		}
+	}
+
+	// generate redirects for the aliases of posts, once all other files are
+	// known so that aliases can't overwrite them
+	if err = b.renderAliases(dir, posts); err != nil {
+		return err
+	}
 
-			item := feeds.Item{
-				Title:       post.Title,
-				Link:        &feeds.Link{Href: url.String()},
-				Description: string(post.Content),
-				Created:     time.Time(post.Date),
+	// remove any files left over from previous builds
+	return b.output.prune()
+}
+
+// renderIndex renders a paginated listing of the given posts to dir. The first
+// page is written to dir/index.html and the others to dir/page/<n>/index.html.
+func (b *Blog) renderIndex(r *tmplRenderer, dir string, name string, posts []*Post, data func(info *IndexInfo) interface{}) error {
+	totalPages := (len(posts)-1)/b.config.PageSize + 1
+	for i := 0; i < totalPages; i++ {
+		info := IndexInfo{
+			PageInfo:   &PageInfo{PageName: name, Blog: &b.config},
+			Posts:      posts[i*b.config.PageSize : min(i*b.config.PageSize+b.config.PageSize, len(posts))],
+			Page:       i + 1,
+			TotalPages: totalPages,
+		}
+
+		filename := filepath.Join(dir, "index.html")
+		if info.Page != 1 {
+			pageDir := filepath.Join(dir, "page", strconv.Itoa(info.Page))
+			if err := os.MkdirAll(pageDir, 0777); err != nil {
+				return err
 			}
+			filename = filepath.Join(pageDir, "index.html")
+		}
 
-			feed.Items = append(feed.Items, &item)
+		if err := b.queuePage(r, filename, name, data(&info)); err != nil {
+			return err
 		}
+	}
 
-		rss, err := feed.ToRss()
+	return nil
+}
+
+// findPosts recursively looks for the sources of all posts in the given
+// directory. A post is either a Markdown file or a page bundle: a directory
+// containing an index Markdown file along with the assets of the post. Other
+// directories are only used for organization. Hidden files and directories are
+// skipped.
+func findPosts(dir string) ([]*postSource, error) {
+	var sources []*postSource
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if path == dir {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 
-		if err = ioutil.WriteFile(filepath.Join(dir, "feed.xml"), []byte(rss), 0666); err != nil {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
 			return err
 		}
+
+		if info.IsDir() {
+			index, err := findBundleIndex(path)
+			if err != nil || index == "" {
+				return err
+			}
+
+			sources = append(sources, &postSource{
+				name:      info.Name(),
+				filename:  filepath.Join(rel, index),
+				bundleDir: path,
+			})
+			return filepath.SkipDir
+		}
+
+		if isPostFile(info.Name()) {
+			sources = append(sources, &postSource{
+				name:     strings.TrimSuffix(info.Name(), filepath.Ext(info.Name())),
+				filename: rel,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return sources, nil
+}
+
+// findBundleIndex returns the name of the index file of the page bundle in the
+// given directory, or an empty string if the directory is not a page bundle.
+func findBundleIndex(dir string) (string, error) {
+	for _, ext := range postExts {
+		name := bundleIndexName + ext
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return name, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
+func isBundleIndex(name string) bool {
+	return isPostFile(name) && strings.TrimSuffix(name, filepath.Ext(name)) == bundleIndexName
+}
+
+func isPostFile(name string) bool {
+	for _, ext := range postExts {
+		if strings.EqualFold(filepath.Ext(name), ext) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (b *Blog) renderPosts() ([]*Post, error) {
-	var posts []*Post
 	dir := filepath.Join(b.dir, "posts")
+	sources, err := findPosts(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := b.newPostCache()
+	if err != nil {
+		return nil, err
+	}
 
-	// parse and render blog posts
-	err := walkFiles(dir, func(file os.FileInfo) error {
-		filename := filepath.Join(dir, file.Name())
-		name := strings.TrimSuffix(file.Name(), ".md")
+	var lastMods map[string]time.Time
+	if b.config.LastmodFromGit {
+		// the build shouldn't depend on the history being available, i.e. in a
+		// source tarball
+		if lastMods, err = gitLastMod(dir); err != nil {
+			b.warn("not using git history for last modification dates: %s", err)
+		}
+	}
+
+	// parse and render blog posts, unless they're cached
+	rendered := make([]*Post, len(sources))
+	err = parallel(b.jobs(), len(sources), func(i int) error {
+		source := sources[i]
+		filename := filepath.Join(dir, source.filename)
 		post := Post{
-			Name:     name,
-			Filename: name + ".html",
+			Name:      source.name,
+			bundleDir: source.bundleDir,
+		}
+
+		// the date in the name of the post is used unless it's set in the
+		// front matter
+		if date, name, ok := splitDatePrefix(source.name); ok {
+			post.Name = name
+			post.Date = PostDate(date)
 		}
-		b.log("rendering %s", filename)
 
 		bytes, err := ioutil.ReadFile(filename)
 		if err != nil {
 			return err
 		}
 
-		if err = b.renderPost(&post, bytes); err != nil {
-			return err
+		key := cache.key(source.filename, bytes)
+		if entry, ok := cache.get(key); ok {
+			b.log("loading %s from cache", filename)
+			if err = b.loadCachedPost(&post, entry); err != nil {
+				return newError(filename, err)
+			}
+		} else {
+			b.log("rendering %s", filename)
+			if err = b.renderPost(&post, bytes); err != nil {
+				return newError(filename, err)
+			}
+			if err = cache.put(key, &post); err != nil {
+				return err
+			}
 		}
 
-		if !post.Draft || !b.config.ExcludeDrafts {
-			posts = append(posts, &post)
+		// fall back to the date of the last commit that changed the post, if
+		// it was changed after it was published
+		lastMod, ok := lastMods[filepath.ToSlash(source.filename)]
+		if ok && post.Updated.IsZero() && lastMod.After(time.Time(post.Date)) {
+			post.Updated = PostDate(lastMod)
 		}
 
+		if err = b.loadPostTerms(&post); err != nil {
+			return newError(filename, err)
+		}
+		if err = b.loadPostURL(&post); err != nil {
+			return newError(filename, err)
+		}
+
+		rendered[i] = &post
 		return nil
 	})
-
 	if err != nil {
 		return nil, err
 	}
 
+	if err = cache.prune(); err != nil {
+		return nil, err
+	}
+
+	var posts []*Post
+	now := time.Now()
+	urls := map[string]*Post{}
+	for _, post := range rendered {
+		if post.Draft && b.config.ExcludeDrafts {
+			continue
+		}
+		// scheduled posts are published once their date has passed
+		if time.Time(post.Date).After(now) && b.config.ExcludeFuture {
+			continue
+		}
+		// expired posts are dropped, or replaced by a stub if requested
+		if !post.Expires.IsZero() && !now.Before(time.Time(post.Expires)) {
+			if !b.hasFeature("expired_stub") {
+				continue
+			}
+			post.Expired = true
+		}
+
+		if other, exists := urls[post.URL]; exists {
+			return nil, fmt.Errorf("posts %s and %s have the same url: %s", other.Name, post.Name, post.URL)
+		}
+		urls[post.URL] = post
+		posts = append(posts, post)
+	}
+
 	// sort posts by publish date
 	sort.Sort(postSlice(posts))
 	return posts, nil
 }
 
-func (b *Blog) newRenderer(templates map[string]*template.Template) *tmplRenderer {
+// copyBundleAssets copies all files of the page bundle of the given post, except
+// for its index, to the assets directory of the post.
+func (b *Blog) copyBundleAssets(dir string, post *Post) error {
+	files, err := ioutil.ReadDir(post.bundleDir)
+	if err != nil {
+		return err
+	}
+
+	assetsDir := filepath.Join(dir, filepath.FromSlash(post.AssetsURL()))
+	for _, file := range files {
+		if !file.IsDir() && isBundleIndex(file.Name()) {
+			continue
+		}
+
+		dst := filepath.Join(assetsDir, file.Name())
+		src := filepath.Join(post.bundleDir, file.Name())
+		if err = b.output.copyFileOrDir(dst, src); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Blog) newRenderer(templates map[string]*template.Template, dir string) *tmplRenderer {
 	return &tmplRenderer{
 		log:       b.log,
 		templates: templates,
+		file:      templateFile(filepath.Join(b.theme.dir, "templates", dir)),
+		output:    b.output,
 	}
 }
 
@@ -251,14 +574,62 @@ func (r *tmplRenderer) renderTemplate(w io.Writer, name string, data interface{}
 }
 
 func (r *tmplRenderer) renderPage(filename string, name string, data interface{}) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+	var buf bytes.Buffer
 
 	r.log("rendering %s", filename)
-	return r.renderTemplate(file, name, data)
+	if err := r.renderTemplate(&buf, name, data); err != nil {
+		return templateError(r.file, err)
+	}
+
+	return r.output.writeFile(filename, buf.Bytes(), 0666)
+}
+
+// queuePage queues a page for rendering by renderQueuedPages.
+// Pages can't overwrite each other, so two pages with the same filename are an
+// error.
+func (b *Blog) queuePage(r *tmplRenderer, filename string, name string, data interface{}) error {
+	page := &queuedPage{
+		renderer: r,
+		filename: filename,
+		name:     name,
+		data:     data,
+	}
+
+	key := filepath.Clean(filename)
+	if other, exists := b.queued[key]; exists {
+		rel, err := filepath.Rel(b.output.dir, filename)
+		if err != nil {
+			rel = filename
+		}
+		return fmt.Errorf("%s and %s have the same output file: %s", other.source(), page.source(), filepath.ToSlash(rel))
+	}
+	b.queued[key] = page
+	b.pages = append(b.pages, page)
+
+	if b.sitemap != nil {
+		return b.sitemap.add(filename, data)
+	}
+
+	return nil
+}
+
+// renderQueuedPages renders all of the queued pages concurrently.
+func (b *Blog) renderQueuedPages() error {
+	pages := b.pages
+	b.pages = nil
+
+	return parallel(b.jobs(), len(pages), func(i int) error {
+		page := pages[i]
+		return page.renderer.renderPage(page.filename, page.name, page.data)
+	})
+}
+
+func (b *Blog) jobs() int {
+	if b.config.Jobs < 1 {
+		return runtime.NumCPU()
+	}
+
+	return b.config.Jobs
 }
 
 func (b *Blog) hasFeature(feature string) bool {
@@ -271,14 +642,33 @@ func (b *Blog) hasFeature(feature string) bool {
 	return false
 }
 
+// absURL resolves the given path against the URL of the blog.
+func (b *Blog) absURL(p string) (string, error) {
+	return resolveURL(b.config.URL, p)
+}
+
+// sitePath prefixes the given URL path with the path of the blog URL, for blogs
+// that aren't hosted at the root of their domain.
+func (b *Blog) sitePath(p string) (string, error) {
+	u, err := url.Parse(b.config.URL)
+	if err != nil {
+		return "", err
+	}
+
+	return resolveURL(u.Path, p)
+}
+
 func (b *Blog) log(format string, v ...interface{}) {
 	if b.logger != nil {
 		b.logger.Printf(format, v...)
 	}
 }
 
-func (b *Blog) fatal(format string, v ...interface{}) {
+// warn logs a message regardless of whether verbose logging is enabled.
+func (b *Blog) warn(format string, v ...interface{}) {
 	if b.logger != nil {
-		b.logger.Fatalf(format, v...)
+		b.logger.Printf("warning: "+format, v...)
+	} else {
+		log.Printf("warning: "+format, v...)
 	}
 }
@@ -0,0 +1,181 @@
+package blog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// cacheVersion must be bumped whenever the way posts are rendered changes
+	cacheVersion = 6
+	cacheExt     = ".json"
+)
+
+// cachedPost holds the parts of a rendered post that are expensive to
+// compute. The front matter is stored as-is and decoded again when the post is
+// loaded from the cache.
+type cachedPost struct {
+	FrontMatterFormat string        `json:"front_matter_format"`
+	FrontMatter       []byte        `json:"front_matter"`
+	TOC               template.HTML `json:"toc"`
+	Content           template.HTML `json:"content"`
+	Summary           template.HTML `json:"summary"`
+	SummaryText       string        `json:"summary_text"`
+}
+
+// postCache is a persistent cache of rendered posts. Entries are keyed by a
+// hash of the source of the post, its filename, the blog config and the theme.
+type postCache struct {
+	dir  string
+	salt []byte
+	mu   sync.Mutex
+	used map[string]struct{}
+}
+
+func (b *Blog) newPostCache() (*postCache, error) {
+	if b.config.CacheDir == "" {
+		return nil, nil
+	}
+
+	// exclude the options that don't affect the rendering of posts
+	config := b.config
+	config.ExcludeDrafts = false
+	config.ExcludeFuture = false
+	config.VersionInfo = ""
+	config.Jobs = 0
+	config.CacheDir = ""
+
+	configBytes, err := yaml.Marshal(&config)
+	if err != nil {
+		return nil, err
+	}
+	themeBytes, err := yaml.Marshal(&b.theme)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n", cacheVersion)
+	h.Write(configBytes)
+	h.Write(themeBytes)
+
+	dir := filepath.Join(b.config.CacheDir, "posts")
+	if err = os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+
+	return &postCache{
+		dir:  dir,
+		salt: h.Sum(nil),
+		used: map[string]struct{}{},
+	}, nil
+}
+
+func (c *postCache) key(filename string, input []byte) string {
+	if c == nil {
+		return ""
+	}
+
+	h := sha256.New()
+	h.Write(c.salt)
+	fmt.Fprintf(h, "%s\n", filepath.ToSlash(filename))
+	h.Write(input)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *postCache) get(key string) (*cachedPost, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.markUsed(key)
+	bytes, err := ioutil.ReadFile(filepath.Join(c.dir, key+cacheExt))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cachedPost
+	if err = json.Unmarshal(bytes, &entry); err != nil {
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+func (c *postCache) put(key string, post *Post) error {
+	if c == nil {
+		return nil
+	}
+
+	entry := cachedPost{
+		FrontMatterFormat: post.frontMatterFormat,
+		FrontMatter:       post.frontMatter,
+		TOC:               post.TOC,
+		Content:           post.Content,
+		Summary:           post.Summary,
+		SummaryText:       post.SummaryText,
+	}
+
+	bytes, err := json.Marshal(&entry)
+	if err != nil {
+		return err
+	}
+
+	c.markUsed(key)
+	return replaceFile(filepath.Join(c.dir, key+cacheExt), bytes, 0666)
+}
+
+func (c *postCache) markUsed(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.used[key] = struct{}{}
+}
+
+// prune removes all entries that weren't used since the cache was opened.
+func (c *postCache) prune() error {
+	if c == nil {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		key := strings.TrimSuffix(file.Name(), cacheExt)
+		if _, used := c.used[key]; !used {
+			if err = os.Remove(filepath.Join(c.dir, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadCachedPost fills in the given post from a cache entry.
+func (b *Blog) loadCachedPost(post *Post, entry *cachedPost) error {
+	if err := b.decodeFrontMatter(entry.FrontMatterFormat, entry.FrontMatter, post); err != nil {
+		return err
+	}
+
+	post.frontMatterFormat = entry.FrontMatterFormat
+	post.frontMatter = entry.FrontMatter
+	post.TOC = entry.TOC
+	post.Content = entry.Content
+	post.Summary = entry.Summary
+	post.SummaryText = entry.SummaryText
+	return nil
+}
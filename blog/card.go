@@ -0,0 +1,317 @@
+package blog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	defaultCardWidth      = 1200
+	defaultCardHeight     = 630
+	defaultCardTitleSize  = 64
+	defaultCardFontSize   = 32
+	defaultCardDateFormat = "January 2, 2006"
+	cardTitleLines        = 3
+)
+
+// Card configures the look of the social cards generated for posts without a
+// cover image. Fonts and the background image are relative to the theme
+// directory.
+type Card struct {
+	Width           int     `yaml:"width"`
+	Height          int     `yaml:"height"`
+	Background      string  `yaml:"background"`
+	BackgroundImage string  `yaml:"background_image"`
+	Foreground      string  `yaml:"foreground"`
+	TitleFont       string  `yaml:"title_font"`
+	TitleSize       float64 `yaml:"title_size"`
+	Font            string  `yaml:"font"`
+	FontSize        float64 `yaml:"font_size"`
+	DateFormat      string  `yaml:"date_format"`
+}
+
+type cardRenderer struct {
+	config     Card
+	background image.Image
+	foreground color.Color
+	titleFont  *opentype.Font
+	font       *opentype.Font
+	// salt is a hash of the resources used to render cards
+	salt []byte
+}
+
+func (b *Blog) loadCardRenderer() error {
+	r := cardRenderer{config: b.theme.Card}
+	if r.config.Width == 0 {
+		r.config.Width = defaultCardWidth
+	}
+	if r.config.Height == 0 {
+		r.config.Height = defaultCardHeight
+	}
+	if r.config.TitleSize == 0 {
+		r.config.TitleSize = defaultCardTitleSize
+	}
+	if r.config.FontSize == 0 {
+		r.config.FontSize = defaultCardFontSize
+	}
+	if r.config.DateFormat == "" {
+		r.config.DateFormat = defaultCardDateFormat
+	}
+	if r.config.Background == "" {
+		r.config.Background = "#ffffff"
+	}
+	if r.config.Foreground == "" {
+		r.config.Foreground = "#000000"
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%+v\n", cacheVersion, r.config)
+
+	var err error
+	if r.titleFont, err = b.loadCardFont(h, r.config.TitleFont, gobold.TTF); err != nil {
+		return err
+	}
+	if r.font, err = b.loadCardFont(h, r.config.Font, goregular.TTF); err != nil {
+		return err
+	}
+
+	background, err := parseColor(r.config.Background)
+	if err != nil {
+		return err
+	}
+	r.background = image.NewUniform(background)
+	if r.foreground, err = parseColor(r.config.Foreground); err != nil {
+		return err
+	}
+
+	if r.config.BackgroundImage != "" {
+		filename := filepath.Join(b.theme.dir, r.config.BackgroundImage)
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if r.background, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		h.Write(data)
+	}
+
+	r.salt = h.Sum(nil)
+	b.cards = &r
+	return nil
+}
+
+// loadCardFont loads the given font file, or the given fallback if no file
+// was configured.
+func (b *Blog) loadCardFont(h io.Writer, filename string, fallback []byte) (*opentype.Font, error) {
+	data := fallback
+	if filename != "" {
+		filename = filepath.Join(b.theme.dir, filename)
+
+		var err error
+		if data, err = ioutil.ReadFile(filename); err != nil {
+			return nil, err
+		}
+	}
+	h.Write(data)
+
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return f, nil
+}
+
+// renderCards generates a social card for every post without a cover image.
+// The filenames of the cards contain a hash of their contents, so cards that
+// still exist in the output directory don't have to be rendered again.
+func (b *Blog) renderCards(dir string, posts []*Post) error {
+	var queue []*Post
+	lines := map[*Post][]string{}
+	for _, post := range posts {
+		if post.Image != "" || post.Expired {
+			continue
+		}
+
+		lines[post] = b.cardLines(post)
+		h := sha256.New()
+		h.Write(b.cards.salt)
+		fmt.Fprintf(h, "%q\n", lines[post])
+		post.CardURL = post.AssetsURL() + "card-" + hex.EncodeToString(h.Sum(nil))[:16] + ".png"
+
+		filename := filepath.Join(dir, filepath.FromSlash(post.CardURL))
+		if _, err := os.Stat(filename); err == nil {
+			b.output.add(filename)
+			continue
+		}
+		queue = append(queue, post)
+	}
+
+	return parallel(b.jobs(), len(queue), func(i int) error {
+		post := queue[i]
+		filename := filepath.Join(dir, filepath.FromSlash(post.CardURL))
+		b.log("rendering %s", filename)
+
+		data, err := b.cards.render(lines[post])
+		if err != nil {
+			return newError(post.Name, err)
+		}
+
+		if err = os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			return err
+		}
+		return b.output.writeFile(filename, data, 0666)
+	})
+}
+
+// cardLines returns the text on the card of the given post: the title, the
+// date and author and the name of the blog.
+func (b *Blog) cardLines(post *Post) []string {
+	var details []string
+	if !post.Date.IsZero() {
+		details = append(details, b.formatDate(b.cards.config.DateFormat, post.Date))
+	}
+	if b.config.Author.Name != "" {
+		details = append(details, b.config.Author.Name)
+	}
+
+	return []string{post.Title, strings.Join(details, " · "), b.config.Title}
+}
+
+func (r *cardRenderer) render(lines []string) ([]byte, error) {
+	title, details, site := lines[0], lines[1], lines[2]
+	width, height := r.config.Width, r.config.Height
+	padding := width / 15
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if _, ok := r.background.(*image.Uniform); ok {
+		draw.Draw(img, img.Bounds(), r.background, image.Point{}, draw.Src)
+	} else {
+		xdraw.CatmullRom.Scale(img, img.Bounds(), r.background, coverRect(r.background.Bounds(), img.Bounds()), draw.Src, nil)
+	}
+
+	titleFace, err := opentype.NewFace(r.titleFont, &opentype.FaceOptions{Size: r.config.TitleSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	face, err := opentype.NewFace(r.font, &opentype.FaceOptions{Size: r.config.FontSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	d := font.Drawer{Dst: img, Src: image.NewUniform(r.foreground)}
+
+	// the title at the top, followed by the details
+	d.Face = titleFace
+	y := fixed.I(padding) + titleFace.Metrics().Ascent
+	for _, line := range wrapText(titleFace, title, width-2*padding, cardTitleLines) {
+		d.Dot = fixed.Point26_6{X: fixed.I(padding), Y: y}
+		d.DrawString(line)
+		y += titleFace.Metrics().Height * 6 / 5
+	}
+
+	d.Face = face
+	d.Dot = fixed.Point26_6{X: fixed.I(padding), Y: y + face.Metrics().Height/2}
+	d.DrawString(details)
+
+	// and the name of the blog at the bottom
+	d.Dot = fixed.Point26_6{X: fixed.I(padding), Y: fixed.I(height-padding) - face.Metrics().Descent}
+	d.DrawString(site)
+
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// wrapText splits the given text into lines that fit in the given width. Text
+// that doesn't fit in the maximum number of lines is cut off with an ellipsis.
+func wrapText(face font.Face, text string, width int, maxLines int) []string {
+	fits := func(s string) bool {
+		return font.MeasureString(face, s).Ceil() <= width
+	}
+
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line == "" {
+			line = word
+		} else if fits(line + " " + word) {
+			line += " " + word
+		} else {
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := lines[maxLines-1]
+		for !fits(last+"…") && strings.Contains(last, " ") {
+			last = last[:strings.LastIndex(last, " ")]
+		}
+		lines[maxLines-1] = last + "…"
+	}
+
+	return lines
+}
+
+// coverRect returns the largest part of src in the center with the same aspect
+// ratio as dst.
+func coverRect(src image.Rectangle, dst image.Rectangle) image.Rectangle {
+	w, h := src.Dx(), src.Dy()
+	if w*dst.Dy() > h*dst.Dx() {
+		w = h * dst.Dx() / dst.Dy()
+	} else {
+		h = w * dst.Dy() / dst.Dx()
+	}
+
+	min := src.Min.Add(image.Pt((src.Dx()-w)/2, (src.Dy()-h)/2))
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(w, h))}
+}
+
+// parseColor parses a color in the #rgb or #rrggbb notation.
+func parseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 || !strings.HasPrefix(s, "#") {
+		return nil, fmt.Errorf("bad color: %s", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("bad color: %s", s)
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
@@ -25,15 +25,32 @@ type License struct {
 	Year int    `yaml:"year"`
 }
 
+type Taxonomy struct {
+	Name          string `yaml:"name"`
+	Path          string `yaml:"path"`
+	Template      string `yaml:"template"`
+	TermsTemplate string `yaml:"terms_template"`
+}
+
 type Config struct {
-	ExcludeDrafts bool
-	VersionInfo   string
-	Title         string   `yaml:"title"`
-	Description   string   `yaml:"description"`
-	URL           string   `yaml:"url"`
-	PageSize      int      `yaml:"page_size"`
-	Features      []string `yaml:"features"`
-	Files         []string `yaml:"files"`
-	Author        Author   `yaml:"author"`
-	License       License  `yaml:"license"`
+	ExcludeDrafts  bool
+	ExcludeFuture  bool
+	VersionInfo    string
+	Jobs           int
+	CacheDir       string
+	Title          string      `yaml:"title"`
+	Description    string      `yaml:"description"`
+	URL            string      `yaml:"url"`
+	PageSize       int         `yaml:"page_size"`
+	SummaryWords   int         `yaml:"summary_words"`
+	Features       []string    `yaml:"features"`
+	Files          []string    `yaml:"files"`
+	FrontMatter    string      `yaml:"front_matter"`
+	Permalink      string      `yaml:"permalink"`
+	Timezone       string      `yaml:"timezone"`
+	LastmodFromGit bool        `yaml:"lastmod_from_git"`
+	Robots         string      `yaml:"robots"`
+	Taxonomies     []*Taxonomy `yaml:"taxonomies"`
+	Author         Author      `yaml:"author"`
+	License        License     `yaml:"license"`
 }
@@ -0,0 +1,126 @@
+package blog
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+var (
+	yamlLineRegex     = regexp.MustCompile(`(?m)^(yaml: |\s+)line (\d+): `)
+	templateLineRegex = regexp.MustCompile(`^template: ([^:]+):(\d+):(?:(\d+):)? ?`)
+)
+
+// Error is an error that occurred while processing a specific source file of
+// the blog. Line and Column are zero if the location in the file is unknown.
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+	// msg is the message of Err without the location that the decoder or
+	// template engine included in it, as the location is reported by Error
+	// itself
+	msg string
+}
+
+func (e *Error) Error() string {
+	msg := e.msg
+	if msg == "" {
+		msg = e.Err.Error()
+	}
+
+	if e.File == "" {
+		return msg
+	}
+	if e.Line > 0 && e.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, msg)
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, msg)
+	}
+
+	return fmt.Sprintf("%s: %s", e.File, msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// shift moves the location of the error n lines down, i.e. to make a line in
+// the front matter relative to the start of the file. Lines mentioned in the
+// message are moved as well.
+func (e *Error) shift(n int) {
+	if e.Line > 0 {
+		e.Line += n
+	}
+	if e.msg != "" {
+		e.msg = yamlLineRegex.ReplaceAllStringFunc(e.msg, func(s string) string {
+			m := yamlLineRegex.FindStringSubmatch(s)
+			line, _ := strconv.Atoi(m[2])
+			return fmt.Sprintf("%sline %d: ", m[1], line+n)
+		})
+	}
+}
+
+// newError attaches the given filename to err. If err is already an *Error,
+// its filename is only filled in if it was missing.
+func newError(filename string, err error) error {
+	var berr *Error
+	if errors.As(err, &berr) {
+		if berr.File == "" {
+			berr.File = filename
+		}
+		return berr
+	}
+
+	return &Error{File: filename, Err: err}
+}
+
+// frontMatterError wraps an error returned by one of the front matter
+// decoders, extracting the line number the error occurred at. Errors about a
+// single line don't mention it in their message, but the unmarshal errors of
+// YAML can list several lines.
+func frontMatterError(err error) error {
+	var perr toml.ParseError
+	if errors.As(err, &perr) {
+		msg := "toml: " + perr.Message
+		if perr.LastKey != "" {
+			msg = fmt.Sprintf("toml: %s (last key %q)", perr.Message, perr.LastKey)
+		}
+		return &Error{Line: perr.Position.Line, Err: err, msg: msg}
+	}
+
+	msg := err.Error()
+	matches := yamlLineRegex.FindAllStringSubmatch(msg, -1)
+	if matches == nil {
+		return &Error{Err: err}
+	}
+
+	line, _ := strconv.Atoi(matches[0][2])
+	if len(matches) == 1 {
+		msg = yamlLineRegex.ReplaceAllString(msg, "$1")
+	}
+	return &Error{Line: line, Err: err, msg: msg}
+}
+
+// templateError wraps an error returned by the template engine, extracting
+// the location the error occurred at. The name of the template is mapped to the
+// file it was loaded from by the given function.
+func templateError(filename func(name string) string, err error) error {
+	m := templateLineRegex.FindStringSubmatchIndex(err.Error())
+	if m == nil {
+		return err
+	}
+
+	msg := err.Error()
+	line, _ := strconv.Atoi(msg[m[4]:m[5]])
+	var column int
+	if m[6] != -1 {
+		column, _ = strconv.Atoi(msg[m[6]:m[7]])
+	}
+	return &Error{File: filename(msg[m[2]:m[3]]), Line: line, Column: column, Err: err, msg: msg[m[1]:]}
+}
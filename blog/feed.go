@@ -0,0 +1,116 @@
+package blog
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/feeds"
+)
+
+var feedFiles = []struct {
+	Feature  string
+	Filename string
+}{
+	{"rss", "feed.xml"},
+	{"atom", "atom.xml"},
+	{"jsonfeed", "feed.json"},
+}
+
+func (b *Blog) hasFeeds() bool {
+	for _, file := range feedFiles {
+		if b.hasFeature(file.Feature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Blog) newFeed(title string, link string, posts []*Post) (*feeds.Feed, error) {
+	var author *feeds.Author
+	if b.config.Author.Name != "" {
+		author = &feeds.Author{Name: b.config.Author.Name, Email: b.config.Author.Email}
+	}
+
+	feed := &feeds.Feed{
+		Id:          link,
+		Title:       title,
+		Link:        &feeds.Link{Href: link},
+		Description: b.config.Description,
+		Author:      author,
+	}
+
+	for _, post := range posts {
+		if post.Draft {
+			continue
+		}
+
+		link, err := b.absURL(post.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		item := feeds.Item{
+			Id:          link,
+			Title:       post.Title,
+			Link:        &feeds.Link{Href: link},
+			Author:      author,
+			Description: string(post.Summary),
+			Content:     string(post.Content),
+			Created:     time.Time(post.Date),
+			Updated:     time.Time(post.LastMod()),
+		}
+		if item.Updated.After(feed.Updated) {
+			feed.Updated = item.Updated
+		}
+
+		feed.Items = append(feed.Items, &item)
+	}
+
+	return feed, nil
+}
+
+// writeFeeds writes the given feed to dir in every format enabled through the
+// features of the blog.
+func (b *Blog) writeFeeds(dir string, feed *feeds.Feed) error {
+	for _, file := range feedFiles {
+		if !b.hasFeature(file.Feature) {
+			continue
+		}
+
+		var err error
+		var data string
+		switch file.Feature {
+		case "rss":
+			data, err = rssFeed(feed).ToRss()
+		case "atom":
+			data, err = feed.ToAtom()
+		case "jsonfeed":
+			data, err = feed.ToJSON()
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = b.output.writeFile(filepath.Join(dir, file.Filename), []byte(data), 0666); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rssFeed returns a copy of the given feed that carries the full content of
+// the posts in the description of the items, as RSS readers expect.
+func rssFeed(feed *feeds.Feed) *feeds.Feed {
+	rss := *feed
+	rss.Items = make([]*feeds.Item, len(feed.Items))
+	for i, item := range feed.Items {
+		rssItem := *item
+		rssItem.Description = rssItem.Content
+		rssItem.Content = ""
+		rss.Items[i] = &rssItem
+	}
+
+	return &rss
+}
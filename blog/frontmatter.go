@@ -0,0 +1,107 @@
+package blog
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	FrontMatterStandard  = "standard"
+	FrontMatterCodeBlock = "codeblock"
+)
+
+const (
+	frontMatterYAML = "yaml"
+	frontMatterTOML = "toml"
+)
+
+var frontMatterDelims = map[string]string{
+	"---": frontMatterYAML,
+	"+++": frontMatterTOML,
+}
+
+// splitFrontMatter splits the front matter off the start of the given input.
+// YAML front matter is delimited by "---" lines and TOML front matter by "+++"
+// lines.
+func splitFrontMatter(input []byte) (format string, meta []byte, body []byte, ok bool) {
+	input = bytes.TrimPrefix(input, []byte("\xef\xbb\xbf"))
+
+	line, rest, _ := bytes.Cut(input, []byte("\n"))
+	delim := string(bytes.TrimRight(line, " \t\r"))
+	format, ok = frontMatterDelims[delim]
+	if !ok {
+		return "", nil, nil, false
+	}
+
+	for offset := 0; offset < len(rest); {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		if string(bytes.TrimRight(line, " \t\r")) == delim {
+			body = rest[min(offset+len(line)+1, len(rest)):]
+			return format, rest[:offset], body, true
+		}
+		offset += len(line) + 1
+	}
+
+	return "", nil, nil, false
+}
+
+// decodeFrontMatter decodes the given front matter into the post. The raw
+// values are also kept around in the Params field of the post. Dates without a
+// time zone are interpreted in the time zone of the blog.
+func (b *Blog) decodeFrontMatter(format string, meta []byte, post *Post) error {
+	if err := unmarshalFrontMatter(format, meta, post); err != nil {
+		return frontMatterError(err)
+	}
+
+	post.Date = post.Date.localize(b.location)
+	post.Updated = post.Updated.localize(b.location)
+	post.Expires = post.Expires.localize(b.location)
+	return nil
+}
+
+func unmarshalFrontMatter(format string, meta []byte, post *Post) error {
+	switch format {
+	case frontMatterYAML:
+		if err := yaml.Unmarshal(meta, post); err != nil {
+			return err
+		}
+		return yaml.Unmarshal(meta, &post.Params)
+	case frontMatterTOML:
+		if err := toml.Unmarshal(meta, &post.Params); err != nil {
+			return err
+		}
+
+		// convert to YAML so that the post fields only have to be tagged once
+		bytes, err := yaml.Marshal(floatTOMLDates(post.Params))
+		if err != nil {
+			return err
+		}
+		return yaml.Unmarshal(bytes, post)
+	default:
+		return fmt.Errorf("unsupported front matter format: %s", format)
+	}
+}
+
+// floatTOMLDates returns a copy of the given TOML values with the local dates
+// formatted without a time zone, so that they're interpreted in the time zone
+// of the blog instead of the one of the machine.
+func floatTOMLDates(params map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		if t, ok := value.(time.Time); ok {
+			switch t.Location().String() {
+			case "datetime-local":
+				value = t.Format("2006-01-02T15:04:05.999999999")
+			case "date-local":
+				value = t.Format(PostDateFormat)
+			}
+		}
+		res[key] = value
+	}
+
+	return res
+}
@@ -0,0 +1,68 @@
+package blog
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gitCommitPrefix is the NUL byte (%x00) that marks the lines with commit dates
+// in the output of git log
+const gitCommitPrefix = "\x00"
+
+// gitLastMod returns the date of the last commit that changed each file in the
+// given directory, according to the local git history. Files that were only
+// committed once are left out, as they were never revised. The filenames are
+// relative to the directory.
+func gitLastMod(dir string) (map[string]time.Time, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "-c", "core.quotePath=false", "log", "--name-only", "--relative", "--format=%x00%cI", "--", ".")
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("executing git log: %s: %s", err, msg)
+		}
+		return nil, fmt.Errorf("executing git log: %s", err)
+	}
+
+	// commits are listed from newest to oldest, so only the first date of every
+	// file is kept
+	res := map[string]time.Time{}
+	commits := map[string]int{}
+	var date time.Time
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, gitCommitPrefix) {
+			var err error
+			if date, err = time.Parse(time.RFC3339, strings.TrimPrefix(line, gitCommitPrefix)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if line == "" {
+			continue
+		}
+		if _, exists := res[line]; !exists {
+			res[line] = date
+		}
+		commits[line]++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for filename, n := range commits {
+		if n < 2 {
+			delete(res, filename)
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,201 @@
+package blog
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type metaTag struct {
+	Attr    string
+	Name    string
+	Content string
+}
+
+// postImageURL returns the absolute URL of the cover image of the given post,
+// or of its generated social card if it doesn't have one. Relative images of
+// page bundles are resolved against the assets directory of the post.
+func (b *Blog) postImageURL(post *Post) (string, error) {
+	if post.Image == "" {
+		if post.CardURL != "" {
+			return b.absURL(post.CardURL)
+		}
+		return "", nil
+	}
+
+	u, err := url.Parse(post.Image)
+	if err != nil {
+		return "", err
+	}
+	if u.IsAbs() {
+		return post.Image, nil
+	}
+
+	p := post.Image
+	if post.bundleDir != "" {
+		p = string(rewriteLink([]byte(p), post.AssetsURL()))
+	}
+	return b.absURL(p)
+}
+
+// postImageAlt returns the alt text of the image returned by postImageURL.
+func postImageAlt(post *Post) string {
+	if post.Image == "" {
+		return post.Title
+	}
+
+	return post.ImageAlt
+}
+
+// postDescription returns the description of the given post, falling back to
+// the text of its summary.
+func postDescription(post *Post) string {
+	if post.Description != "" {
+		return post.Description
+	}
+
+	return strings.TrimSpace(post.SummaryText)
+}
+
+// openGraph renders the OpenGraph metadata of the given post.
+func (b *Blog) openGraph(post *Post) (template.HTML, error) {
+	link, err := b.absURL(post.URL)
+	if err != nil {
+		return "", err
+	}
+	image, err := b.postImageURL(post)
+	if err != nil {
+		return "", err
+	}
+
+	tags := []metaTag{
+		{"property", "og:type", "article"},
+		{"property", "og:site_name", b.config.Title},
+		{"property", "og:title", post.Title},
+		{"property", "og:description", postDescription(post)},
+		{"property", "og:url", link},
+		{"property", "og:image", image},
+	}
+	if image != "" {
+		tags = append(tags, metaTag{"property", "og:image:alt", postImageAlt(post)})
+	}
+	if !post.Date.IsZero() {
+		tags = append(tags, metaTag{"property", "article:published_time", post.Date.RFC3339()})
+	}
+	if time.Time(post.Updated).After(time.Time(post.Date)) {
+		tags = append(tags, metaTag{"property", "article:modified_time", post.Updated.RFC3339()})
+	}
+	tags = append(tags, metaTag{"property", "article:author", b.config.Author.Name})
+	for _, tag := range post.Taxonomies["tags"] {
+		tags = append(tags, metaTag{"property", "article:tag", tag})
+	}
+
+	return renderMetaTags(tags), nil
+}
+
+// twitterCard renders the Twitter Card metadata of the given post. Posts with
+// a cover image get a large image card.
+func (b *Blog) twitterCard(post *Post) (template.HTML, error) {
+	image, err := b.postImageURL(post)
+	if err != nil {
+		return "", err
+	}
+
+	card := "summary"
+	if image != "" {
+		card = "summary_large_image"
+	}
+
+	tags := []metaTag{
+		{"name", "twitter:card", card},
+		{"name", "twitter:title", post.Title},
+		{"name", "twitter:description", postDescription(post)},
+		{"name", "twitter:image", image},
+	}
+	if image != "" {
+		tags = append(tags, metaTag{"name", "twitter:image:alt", postImageAlt(post)})
+	}
+
+	return renderMetaTags(tags), nil
+}
+
+// jsonLD renders a JSON-LD BlogPosting object for the given post.
+func (b *Blog) jsonLD(post *Post) (template.HTML, error) {
+	link, err := b.absURL(post.URL)
+	if err != nil {
+		return "", err
+	}
+	image, err := b.postImageURL(post)
+	if err != nil {
+		return "", err
+	}
+
+	obj := map[string]interface{}{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         post.Title,
+		"url":              link,
+		"mainEntityOfPage": link,
+	}
+	if description := postDescription(post); description != "" {
+		obj["description"] = description
+	}
+	if image != "" {
+		obj["image"] = image
+	}
+	if !post.Date.IsZero() {
+		obj["datePublished"] = post.Date.RFC3339()
+	}
+	if lastMod := post.LastMod(); !lastMod.IsZero() {
+		obj["dateModified"] = lastMod.RFC3339()
+	}
+	if tags := post.Taxonomies["tags"]; len(tags) > 0 {
+		obj["keywords"] = tags
+	}
+	if b.config.Author.Name != "" {
+		obj["author"] = map[string]interface{}{
+			"@type": "Person",
+			"name":  b.config.Author.Name,
+			"url":   b.config.URL,
+		}
+	}
+	if b.config.License.URL != "" {
+		obj["license"] = b.config.License.URL
+	} else if b.config.License.Name != "" {
+		obj["license"] = b.config.License.Name
+	}
+	if b.config.Title != "" {
+		obj["publisher"] = map[string]interface{}{
+			"@type": "Organization",
+			"name":  b.config.Title,
+			"url":   b.config.URL,
+		}
+	}
+
+	// characters like < are escaped, so it's safe to embed in a script tag
+	bytes, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(fmt.Sprintf("<script type=\"application/ld+json\">%s</script>\n", bytes)), nil
+}
+
+// renderMetaTags renders the given meta tags, skipping the ones without
+// content.
+func renderMetaTags(tags []metaTag) template.HTML {
+	var sb strings.Builder
+	for _, tag := range tags {
+		if tag.Content == "" {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "<meta %s=\"%s\" content=\"%s\">\n",
+			tag.Attr, template.HTMLEscapeString(tag.Name), template.HTMLEscapeString(tag.Content))
+	}
+
+	return template.HTML(sb.String())
+}
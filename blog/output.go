@@ -0,0 +1,156 @@
+package blog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// output keeps track of the files written to the output directory during a
+// build. Files with unchanged contents are not rewritten and files that were
+// not written during the build are pruned afterwards.
+type output struct {
+	dir   string
+	mu    sync.Mutex
+	files map[string]struct{}
+}
+
+func newOutput(dir string) *output {
+	return &output{
+		dir:   dir,
+		files: map[string]struct{}{},
+	}
+}
+
+func (o *output) add(filename string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.files[filepath.Clean(filename)] = struct{}{}
+}
+
+// has reports whether the given file was written during the build.
+func (o *output) has(filename string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, exists := o.files[filepath.Clean(filename)]
+	return exists
+}
+
+// writeFile writes data to the given file, unless it already has the exact
+// same contents. Files are replaced instead of being written to in place.
+func (o *output) writeFile(filename string, data []byte, mode os.FileMode) error {
+	o.add(filename)
+
+	if existing, err := ioutil.ReadFile(filename); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	return replaceFile(filename, data, mode)
+}
+
+func (o *output) copyFileOrDir(dst string, src string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		if err = os.MkdirAll(dst, info.Mode()); err != nil {
+			return err
+		}
+		err = o.copyDir(dst, src)
+	} else {
+		if err = os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+			return err
+		}
+		err = o.copyFile(dst, src, info.Mode())
+	}
+
+	return err
+}
+
+func (o *output) copyFile(dst string, src string, mode os.FileMode) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return o.writeFile(dst, data, mode)
+}
+
+func (o *output) copyDir(dst string, src string) error {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		dst := filepath.Join(dst, file.Name())
+		src := filepath.Join(src, file.Name())
+
+		if file.IsDir() {
+			if err = os.MkdirAll(dst, file.Mode()); err != nil {
+				return err
+			}
+
+			if err = o.copyDir(dst, src); err != nil {
+				return err
+			}
+		} else {
+			if err = o.copyFile(dst, src, file.Mode()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// prune removes all files that were not written during the build, as well as
+// any directories left empty.
+func (o *output) prune() error {
+	var dirs []string
+	err := filepath.Walk(o.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path != o.dir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+
+		if _, exists := o.files[filepath.Clean(path)]; !exists {
+			return os.Remove(path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// remove empty directories, starting with the deepest ones
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) == 0 {
+			if err = os.Remove(dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
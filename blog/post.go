@@ -1,28 +1,79 @@
 package blog
 
 import (
+	"fmt"
 	"html/template"
+	"path"
+	"strings"
 	"time"
+
+	yaml "gopkg.in/yaml.v3"
 )
 
 const (
-	PostDateFormat = "2006-01-02"
+	PostDateFormat   = "2006-01-02"
+	DefaultPermalink = "/post/:slug.html"
 )
 
 type Post struct {
-	Name        string
+	Name string
+	// Filename is the URL of the post relative to /post/.
+	//
+	// Deprecated: Use URL instead.
 	Filename    string
-	Title       string   `yaml:"title"`
-	Date        PostDate `yaml:"date"`
-	Draft       bool     `yaml:"draft"`
+	URL         string
+	Slug        string                 `yaml:"slug"`
+	Title       string                 `yaml:"title"`
+	Description string                 `yaml:"description"`
+	Image       string                 `yaml:"image"`
+	ImageAlt    string                 `yaml:"image_alt"`
+	CardURL     string                 `yaml:"-"`
+	Date        PostDate               `yaml:"date"`
+	Updated     PostDate               `yaml:"updated"`
+	Draft       bool                   `yaml:"draft"`
+	Unlisted    bool                   `yaml:"unlisted"`
+	Expires     PostDate               `yaml:"expires"`
+	Expired     bool                   `yaml:"-"`
+	Tags        Tags                   `yaml:"tags"`
+	Aliases     []string               `yaml:"aliases"`
+	Params      map[string]interface{} `yaml:"-"`
+	Taxonomies  map[string][]string    `yaml:"-"`
 	TOC         template.HTML
 	Content     template.HTML
 	Summary     template.HTML
 	SummaryText string
+
+	frontMatterFormat string
+	frontMatter       []byte
+	bundleDir         string
 }
 
 type PostDate time.Time
 
+// Tags is a list of tags, which can also be given as a single string like the
+// terms of other taxonomies.
+type Tags []string
+
+var (
+	// floatingZone marks dates that were specified without a time zone,
+	// until they're localized to the time zone of the blog
+	floatingZone = time.FixedZone("floating", 0)
+
+	postDateLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999 -0700",
+		"2006-01-02 15:04:05.999999999 -0700 MST",
+	}
+	floatingPostDateLayouts = []string{
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02 15:04",
+		PostDateFormat,
+	}
+)
+
 type PostInfo struct {
 	*PageInfo
 	Post *Post
@@ -42,20 +93,166 @@ func (s postSlice) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 
+// listedPosts returns the given posts without the unlisted and expired ones.
+func listedPosts(posts []*Post) []*Post {
+	var res []*Post
+	for _, post := range posts {
+		if !post.Unlisted && !post.Expired {
+			res = append(res, post)
+		}
+	}
+
+	return res
+}
+
+// loadPostURL fills in the slug of the given post if it wasn't set in the front
+// matter and determines the URL of the post using the permalink pattern.
+func (b *Blog) loadPostURL(post *Post) error {
+	if post.Slug == "" {
+		post.Slug = post.Name
+	}
+	if strings.ContainsAny(post.Slug, "/?#") {
+		return fmt.Errorf("bad slug: %s", post.Slug)
+	}
+
+	permalink := b.config.Permalink
+	if permalink == "" {
+		permalink = DefaultPermalink
+	}
+
+	date := time.Time(post.Date)
+	url := strings.NewReplacer(
+		":year", fmt.Sprintf("%04d", date.Year()),
+		":month", fmt.Sprintf("%02d", date.Month()),
+		":day", fmt.Sprintf("%02d", date.Day()),
+		":slug", post.Slug,
+		":name", post.Name,
+	).Replace(permalink)
+
+	post.URL = path.Clean("/" + url)
+	if post.URL == "/" {
+		return fmt.Errorf("bad permalink: %s", permalink)
+	}
+	if strings.HasSuffix(url, "/") {
+		post.URL += "/"
+	}
+
+	// keep links of the form /post/{{.Filename}} working for older themes
+	if strings.HasPrefix(post.URL, "/post/") {
+		post.Filename = strings.TrimPrefix(post.URL, "/post/")
+	} else {
+		post.Filename = "../" + strings.TrimPrefix(post.URL, "/")
+	}
+
+	return nil
+}
+
+// splitDatePrefix splits a Jekyll-style date prefix (i.e. 2024-03-07-my-post)
+// off the given name.
+func splitDatePrefix(name string) (time.Time, string, bool) {
+	const prefixLen = len(PostDateFormat) + 1
+	if len(name) <= prefixLen || name[prefixLen-1] != '-' {
+		return time.Time{}, "", false
+	}
+
+	date, err := time.ParseInLocation(PostDateFormat, name[:prefixLen-1], floatingZone)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+
+	return date, name[prefixLen:], true
+}
+
+// LastMod returns the date the post was last updated, or its publish date if
+// it wasn't updated since.
+func (p *Post) LastMod() PostDate {
+	if time.Time(p.Updated).After(time.Time(p.Date)) {
+		return p.Updated
+	}
+
+	return p.Date
+}
+
+// AssetsURL returns the URL of the directory the assets of a page bundle are
+// copied to.
+func (p *Post) AssetsURL() string {
+	if strings.HasSuffix(p.URL, "/") {
+		return p.URL
+	}
+
+	return strings.TrimSuffix(p.URL, path.Ext(p.URL)) + "/"
+}
+
+func (t *Tags) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = nil
+		if value.Tag != "!!null" {
+			*t = Tags{value.Value}
+		}
+		return nil
+	}
+
+	var tags []string
+	if err := value.Decode(&tags); err != nil {
+		return err
+	}
+
+	*t = tags
+	return nil
+}
+
 func (d PostDate) MarshalText() ([]byte, error) {
 	return []byte(d.RFC3339()), nil
 }
 
 func (d *PostDate) UnmarshalText(data []byte) error {
-	parsedDate, err := time.Parse(time.RFC3339, string(data))
+	date, err := parsePostDate(string(data))
 	if err != nil {
 		return err
 	}
 
-	*d = PostDate(parsedDate)
+	*d = date
 	return nil
 }
 
+// parsePostDate parses a date in one of the supported layouts. Dates without a
+// time zone are floating until they're localized.
+func parsePostDate(s string) (PostDate, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range postDateLayouts {
+		if date, err := time.Parse(layout, s); err == nil {
+			return PostDate(date), nil
+		}
+	}
+	for _, layout := range floatingPostDateLayouts {
+		if date, err := time.ParseInLocation(layout, s, floatingZone); err == nil {
+			return PostDate(date), nil
+		}
+	}
+
+	return PostDate{}, fmt.Errorf("unsupported date format: %s", s)
+}
+
+// localize interprets a floating date in the given time zone. Other dates are
+// returned as-is.
+func (d PostDate) localize(loc *time.Location) PostDate {
+	t := time.Time(d)
+	if t.Location() != floatingZone {
+		return d
+	}
+
+	return PostDate(time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc))
+}
+
+// In returns the date in the given time zone.
+func (d PostDate) In(loc *time.Location) PostDate {
+	return PostDate(time.Time(d).In(loc))
+}
+
+func (d PostDate) IsZero() bool {
+	return time.Time(d).IsZero()
+}
+
 func (d PostDate) Format(layout string) string {
 	return time.Time(d).Format(layout)
 }
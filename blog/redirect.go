@@ -0,0 +1,131 @@
+package blog
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	netlifyRedirectsFilename = "_redirects"
+	nginxRedirectsFilename   = "nginx-redirects.conf"
+)
+
+var redirectTemplate = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="canonical" href="{{.URL}}">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url={{.URL}}">
+</head>
+<body>
+<p>This page has moved to <a href="{{.URL}}">{{.URL}}</a>.</p>
+</body>
+</html>
+`))
+
+type redirect struct {
+	From string
+	To   string
+}
+
+// renderAliases writes a redirect page to the URL of every alias of the given
+// posts. Depending on the enabled features, redirect rules for Netlify and
+// nginx are written as well. Aliases may not conflict with any of the files
+// written before.
+func (b *Blog) renderAliases(dir string, posts []*Post) error {
+	var redirects []redirect
+	urls := map[string]string{}
+	for _, post := range posts {
+		urls[post.URL] = post.Name
+	}
+
+	for _, post := range posts {
+		for _, alias := range post.Aliases {
+			from := path.Clean("/" + alias)
+			if strings.HasSuffix(alias, "/") || path.Ext(from) == "" {
+				from = strings.TrimSuffix(from, "/") + "/"
+			}
+			if from == "/" {
+				return fmt.Errorf("bad alias for post %s: %s", post.Name, alias)
+			}
+			if name, exists := urls[from]; exists {
+				return fmt.Errorf("alias %s of post %s conflicts with post %s", alias, post.Name, name)
+			}
+			urls[from] = post.Name
+
+			filename := urlToFilename(dir, from)
+			if b.output.has(filename) {
+				return fmt.Errorf("alias %s of post %s conflicts with an existing page", alias, post.Name)
+			}
+
+			to, err := b.absURL(post.URL)
+			if err != nil {
+				return err
+			}
+
+			var buf bytes.Buffer
+			err = redirectTemplate.Execute(&buf, struct {
+				Title string
+				URL   string
+			}{post.Title, to})
+			if err != nil {
+				return err
+			}
+
+			if err = os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+				return err
+			}
+			if err = b.output.writeFile(filename, buf.Bytes(), 0666); err != nil {
+				return err
+			}
+
+			// the redirect rules are relative to the root of the domain
+			r := redirect{}
+			if r.From, err = b.sitePath(from); err != nil {
+				return err
+			}
+			if r.To, err = b.sitePath(post.URL); err != nil {
+				return err
+			}
+			redirects = append(redirects, r)
+		}
+	}
+
+	if b.hasFeature("netlify_redirects") {
+		var buf bytes.Buffer
+		for _, r := range redirects {
+			fmt.Fprintf(&buf, "%s %s 301\n", r.From, r.To)
+		}
+
+		if err := b.output.writeFile(filepath.Join(dir, netlifyRedirectsFilename), buf.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+
+	if b.hasFeature("nginx_redirects") {
+		var buf bytes.Buffer
+		buf.WriteString("map $uri $blogen_redirect {\n")
+		for _, r := range redirects {
+			fmt.Fprintf(&buf, "    %s %s;\n", r.From, r.To)
+			// $uri has to match exactly, so directories are mapped both with
+			// and without their trailing slash
+			if from := strings.TrimSuffix(r.From, "/"); from != r.From {
+				fmt.Fprintf(&buf, "    %s %s;\n", from, r.To)
+			}
+		}
+		buf.WriteString("}\n")
+
+		if err := b.output.writeFile(filepath.Join(dir, nginxRedirectsFilename), buf.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
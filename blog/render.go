@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"net/url"
+	"path"
+	"regexp"
 	"strings"
 
 	"github.com/alecthomas/chroma/v2"
@@ -13,7 +16,10 @@ import (
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/russross/blackfriday/v2"
-	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	moreRegex = regexp.MustCompile(`^<!--\s*more\s*-->$`)
 )
 
 func (b *Blog) renderPost(post *Post, input []byte) error {
@@ -21,52 +27,135 @@ func (b *Blog) renderPost(post *Post, input []byte) error {
 	var bodyBuf bytes.Buffer
 	var sumBuf bytes.Buffer
 	var sumText string
+	var foundInfo bool
+
+	// parse post info from the front matter, unless the legacy mode of
+	// storing it in the first code block of the post is enabled
+	if b.config.FrontMatter != FrontMatterCodeBlock {
+		format, meta, body, ok := splitFrontMatter(input)
+		if !ok {
+			return errors.New("post info not found")
+		}
+		if err := b.decodeFrontMatter(format, meta, post); err != nil {
+			// account for the line of the opening delimiter
+			var berr *Error
+			if errors.As(err, &berr) {
+				berr.shift(1)
+			}
+			return err
+		}
+		post.frontMatterFormat = format
+		post.frontMatter = meta
+		input = body
+		foundInfo = true
+	}
 
 	renderer := blackfriday.NewHTMLRenderer(
 		blackfriday.HTMLRendererParameters{
 			Flags: blackfriday.CommonHTMLFlags,
 		},
 	)
+	// the renderer is stateful (i.e. while rendering images), so the summary
+	// needs a renderer of its own
+	sumRenderer := blackfriday.NewHTMLRenderer(
+		blackfriday.HTMLRendererParameters{
+			Flags: blackfriday.CommonHTMLFlags,
+		},
+	)
 	parser := blackfriday.New(
 		blackfriday.WithRenderer(renderer),
 		blackfriday.WithExtensions(blackfriday.CommonExtensions|blackfriday.AutoHeadingIDs|blackfriday.Footnotes),
 	)
 	ast := parser.Parse(input)
 
+	// in legacy mode, the post info is stored in the first code block
+	var infoNode *blackfriday.Node
+	if !foundInfo {
+		ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+			if node.Type == blackfriday.CodeBlock {
+				infoNode = node
+				return blackfriday.Terminate
+			}
+			return blackfriday.GoToNext
+		})
+		if infoNode == nil {
+			return errors.New("post info not found")
+		}
+
+		if err := b.decodeFrontMatter(frontMatterYAML, infoNode.Literal, post); err != nil {
+			// make the line relative to the start of the file
+			var berr *Error
+			if errors.As(err, &berr) && berr.Line > 0 {
+				if start, ok := literalLine(input, infoNode.Literal); ok {
+					berr.shift(start - 1)
+				} else {
+					berr.Line = 0
+				}
+			}
+			return err
+		}
+		post.frontMatterFormat = frontMatterYAML
+		post.frontMatter = infoNode.Literal
+	}
+
+	// make relative links in page bundles point to the copied assets
+	if post.bundleDir != "" {
+		if err := b.loadPostURL(post); err != nil {
+			return err
+		}
+		assetsURL, err := b.sitePath(post.AssetsURL())
+		if err != nil {
+			return err
+		}
+		rewriteLinks(ast, assetsURL)
+	}
+
 	renderTOC(renderer, &tocBuf, ast)
 
 	var bodyErr error
-	var foundInfo bool
 	var foundSum bool
 	var foundTitle bool
 	var sumNode *blackfriday.Node
 
-	// parse post info, render summary and render body
+	// everything before a <!--more--> marker is used as the summary, if the post
+	// has one
+	moreNode := findMoreNode(ast)
+	inSum := moreNode != nil
+
+	// render summary and render body
 	ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
 		var skipSum bool
 
+		if node == moreNode {
+			// close the elements of the summary the marker is nested in
+			for parent := node.Parent; parent != ast; parent = parent.Parent {
+				sumRenderer.RenderNode(&sumBuf, parent, false)
+			}
+			inSum = false
+			foundSum = true
+			return blackfriday.GoToNext
+		}
+
 		switch node.Type {
 		case blackfriday.CodeBlock:
 			if entering {
-				if !foundInfo {
-					// parse post info
-					if err := yaml.Unmarshal(node.Literal, post); err != nil {
-						bodyErr = err
-						return blackfriday.Terminate
-					}
-					foundInfo = true
-				} else {
+				if node != infoNode {
 					// syntax-highlight any code blocks
-					if err := b.renderCode(&bodyBuf, node.Literal, node.CodeBlockData); err != nil {
+					var codeBuf bytes.Buffer
+					if err := b.renderCode(&codeBuf, node.Literal, node.CodeBlockData); err != nil {
 						bodyErr = err
 						return blackfriday.Terminate
 					}
+					if inSum {
+						sumBuf.Write(codeBuf.Bytes())
+					}
+					bodyBuf.Write(codeBuf.Bytes())
 				}
 				return blackfriday.SkipChildren
 			}
 		case blackfriday.Paragraph:
 			// use the first paragraph as a summary for the post
-			if !foundSum {
+			if !foundSum && moreNode == nil {
 				if entering && sumNode == nil {
 					sumNode = node
 					skipSum = true
@@ -95,8 +184,8 @@ func (b *Blog) renderPost(post *Post, input []byte) error {
 			}
 		}
 
-		if sumNode != nil && !skipSum {
-			status := renderer.RenderNode(&sumBuf, node, entering)
+		if (sumNode != nil && !skipSum) || inSum {
+			status := sumRenderer.RenderNode(&sumBuf, node, entering)
 			if status == blackfriday.Terminate {
 				return blackfriday.Terminate
 			}
@@ -108,12 +197,6 @@ func (b *Blog) renderPost(post *Post, input []byte) error {
 	if bodyErr != nil {
 		return bodyErr
 	}
-	if !foundInfo {
-		return errors.New("post info not found")
-	}
-	if !foundSum {
-		return errors.New("couldn't extract post summary")
-	}
 
 	renderer.RenderFooter(&bodyBuf, ast)
 
@@ -121,9 +204,101 @@ func (b *Blog) renderPost(post *Post, input []byte) error {
 	post.Content = template.HTML(bodyBuf.Bytes())
 	post.Summary = template.HTML(sumBuf.Bytes())
 	post.SummaryText = sumText
+	if !foundSum {
+		post.Summary = ""
+		post.SummaryText = ""
+	}
+
+	// an explicit summary takes precedence over the one found in the post
+	switch summary := post.Params["summary"].(type) {
+	case nil:
+		// the first paragraph is cut off if it's too long
+		if moreNode == nil && b.config.SummaryWords > 0 && len(strings.Fields(post.SummaryText)) > b.config.SummaryWords {
+			post.Summary, post.SummaryText = truncateSummary(post.SummaryText, b.config.SummaryWords)
+		}
+	case string:
+		post.Summary, post.SummaryText = renderSummary(summary)
+	default:
+		return fmt.Errorf("bad value for summary: %v", summary)
+	}
+
 	return nil
 }
 
+// literalLine returns the line of the input that the given literal of a code
+// block starts at.
+func literalLine(input []byte, literal []byte) (int, bool) {
+	first, _, _ := bytes.Cut(literal, []byte("\n"))
+	if len(bytes.TrimSpace(first)) == 0 {
+		return 0, false
+	}
+
+	offset := bytes.Index(input, first)
+	if offset < 0 {
+		return 0, false
+	}
+
+	return bytes.Count(input[:offset], []byte("\n")) + 1, true
+}
+
+// findMoreNode returns the <!--more--> marker in the given document, if any.
+// The marker is either a block of its own or part of a paragraph.
+func findMoreNode(ast *blackfriday.Node) *blackfriday.Node {
+	var res *blackfriday.Node
+	ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		switch node.Type {
+		case blackfriday.CodeBlock:
+			return blackfriday.SkipChildren
+		case blackfriday.HTMLBlock, blackfriday.HTMLSpan:
+			if moreRegex.Match(bytes.TrimSpace(node.Literal)) {
+				res = node
+				return blackfriday.Terminate
+			}
+		}
+		return blackfriday.GoToNext
+	})
+
+	return res
+}
+
+// renderSummary renders a summary that was given in the front matter. Like the
+// first paragraph of a post, a summary of a single paragraph isn't wrapped in
+// paragraph tags.
+func renderSummary(input string) (template.HTML, string) {
+	renderer := blackfriday.NewHTMLRenderer(
+		blackfriday.HTMLRendererParameters{
+			Flags: blackfriday.CommonHTMLFlags,
+		},
+	)
+	ast := blackfriday.New(blackfriday.WithExtensions(blackfriday.CommonExtensions)).Parse([]byte(input))
+
+	var buf bytes.Buffer
+	var text string
+	ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && node.Type == blackfriday.Text {
+			text += strings.Replace(string(node.Literal), "\n", " ", -1)
+		}
+		if node.Type == blackfriday.Paragraph && node.Parent == ast && ast.FirstChild == ast.LastChild {
+			return blackfriday.GoToNext
+		}
+		return renderer.RenderNode(&buf, node, entering)
+	})
+
+	return template.HTML(buf.Bytes()), text
+}
+
+// truncateSummary uses the first n words of the given summary text as the
+// summary.
+func truncateSummary(text string, n int) (template.HTML, string) {
+	words := strings.Fields(text)
+	text = strings.Join(words[:min(n, len(words))], " ")
+	if len(words) > n {
+		text += "…"
+	}
+
+	return template.HTML(template.HTMLEscapeString(text)), text
+}
+
 func (b *Blog) renderCode(w io.Writer, literal []byte, data blackfriday.CodeBlockData) error {
 	lang := string(data.Info)
 	text := string(bytes.TrimRight(literal, "\n"))
@@ -154,6 +329,32 @@ func (b *Blog) renderCode(w io.Writer, literal []byte, data blackfriday.CodeBloc
 	return formatter.Format(w, codeStyle, iterator)
 }
 
+// rewriteLinks resolves the relative destinations of all links and images in
+// the given document against the given base URL path.
+func rewriteLinks(ast *blackfriday.Node, base string) {
+	ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && (node.Type == blackfriday.Link || node.Type == blackfriday.Image) && node.NoteID == 0 {
+			node.Destination = rewriteLink(node.Destination, base)
+		}
+		return blackfriday.GoToNext
+	})
+}
+
+func rewriteLink(dest []byte, base string) []byte {
+	u, err := url.Parse(string(dest))
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return dest
+	}
+
+	trailingSlash := strings.HasSuffix(u.Path, "/")
+	u.Path = path.Join(base, u.Path)
+	if trailingSlash {
+		u.Path += "/"
+	}
+
+	return []byte(u.String())
+}
+
 // copied from the blackfriday source and modified to exclude the title of the post
 func renderTOC(r *blackfriday.HTMLRenderer, w io.Writer, ast *blackfriday.Node) {
 	buf := bytes.Buffer{}
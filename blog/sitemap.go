@@ -0,0 +1,137 @@
+package blog
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sitemapFilename = "sitemap.xml"
+	robotsFilename  = "robots.txt"
+	sitemapXMLNS    = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	defaultRobots   = "User-agent: *\nDisallow:\n"
+)
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name      `xml:"urlset"`
+	XMLNS   string        `xml:"xmlns,attr"`
+	URLs    []*sitemapURL `xml:"url"`
+}
+
+// sitemap keeps track of the pages written to the output directory.
+type sitemap struct {
+	dir     string
+	baseURL string
+	urls    []*sitemapURL
+}
+
+func newSitemap(dir string, baseURL string) *sitemap {
+	return &sitemap{dir: dir, baseURL: baseURL}
+}
+
+// add adds the page at the given filename to the sitemap, unless it's marked
+// as noindex. The last modification date is derived from the posts in the page
+// data, if any.
+func (s *sitemap) add(filename string, data interface{}) error {
+	if info, ok := data.(*PostInfo); ok && info.NoIndex {
+		return nil
+	}
+
+	rel, err := filepath.Rel(s.dir, filename)
+	if err != nil {
+		return err
+	}
+
+	// index pages are listed by the URL of their directory, including the
+	// trailing slash
+	p := "/" + filepath.ToSlash(rel)
+	if path.Base(p) == "index.html" {
+		p = strings.TrimSuffix(p, "index.html")
+	}
+
+	loc, err := resolveURL(s.baseURL, p)
+	if err != nil {
+		return err
+	}
+
+	u := sitemapURL{Loc: loc}
+	if lastMod := pageLastMod(data); !lastMod.IsZero() {
+		u.LastMod = lastMod.Format(time.RFC3339)
+	}
+
+	s.urls = append(s.urls, &u)
+	return nil
+}
+
+func (s *sitemap) encode() ([]byte, error) {
+	urls := make([]*sitemapURL, len(s.urls))
+	copy(urls, s.urls)
+	sort.Slice(urls, func(i, j int) bool {
+		return urls[i].Loc < urls[j].Loc
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(&sitemapURLSet{XMLNS: sitemapXMLNS, URLs: urls}); err != nil {
+		return nil, err
+	}
+
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// pageLastMod returns the date of the most recently updated post in the given
+// page data.
+func pageLastMod(data interface{}) time.Time {
+	var posts []*Post
+	switch info := data.(type) {
+	case *PostInfo:
+		posts = []*Post{info.Post}
+	case *IndexInfo:
+		posts = info.Posts
+	case *TermInfo:
+		posts = info.Posts
+	case *TermsInfo:
+		for _, term := range info.Terms {
+			posts = append(posts, term.Posts...)
+		}
+	}
+
+	var lastMod time.Time
+	for _, post := range posts {
+		if date := time.Time(post.LastMod()); date.After(lastMod) {
+			lastMod = date
+		}
+	}
+
+	return lastMod
+}
+
+func (b *Blog) writeRobots(dir string) error {
+	robots := b.config.Robots
+	if robots == "" {
+		robots = defaultRobots
+	}
+
+	sitemapURL, err := b.absURL(sitemapFilename)
+	if err != nil {
+		return err
+	}
+
+	robots = strings.TrimRight(robots, "\n") + fmt.Sprintf("\n\nSitemap: %s\n", sitemapURL)
+	return b.output.writeFile(filepath.Join(dir, robotsFilename), []byte(robots), 0666)
+}
@@ -0,0 +1,21 @@
+package blog
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// exchangeDirs atomically exchanges the directories a and b. It reports false
+// if the kernel or file system doesn't support this.
+func exchangeDirs(a string, b string) (bool, error) {
+	err := unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+	switch err {
+	case nil:
+		return true, nil
+	case unix.ENOSYS, unix.EINVAL, unix.EOPNOTSUPP:
+		return false, nil
+	}
+
+	return false, &os.LinkError{Op: "renameat2", Old: a, New: b, Err: err}
+}
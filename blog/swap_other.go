@@ -0,0 +1,9 @@
+//go:build !linux
+
+package blog
+
+// exchangeDirs atomically exchanges the directories a and b. This isn't
+// supported on this platform.
+func exchangeDirs(a string, b string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,250 @@
+package blog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type Term struct {
+	Name  string
+	Slug  string
+	URL   string
+	Posts []*Post
+}
+
+type TermInfo struct {
+	*IndexInfo
+	Taxonomy *Taxonomy
+	Term     *Term
+}
+
+type TermsInfo struct {
+	*PageInfo
+	Taxonomy *Taxonomy
+	Terms    []*Term
+}
+
+// reservedPaths are the top-level directories of the output that taxonomies
+// can't use.
+var reservedPaths = []string{"post", "page", "static"}
+
+type termSlice []*Term
+
+func (s termSlice) Len() int {
+	return len(s)
+}
+
+func (s termSlice) Less(i, j int) bool {
+	return s[i].Slug < s[j].Slug
+}
+
+func (s termSlice) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// collectTerms groups the given posts by the terms returned by the given
+// function. The order of the posts is preserved within each term. Terms that
+// only differ in case are merged, but other terms with the same slug are an
+// error.
+func collectTerms(posts []*Post, urlPath string, terms func(post *Post) []string) ([]*Term, error) {
+	var res []*Term
+	bySlug := map[string]*Term{}
+
+	for _, post := range posts {
+		for _, name := range terms(post) {
+			slug := slugify(name)
+			if slug == "" {
+				continue
+			}
+
+			term, exists := bySlug[slug]
+			if !exists {
+				term = &Term{
+					Name: name,
+					Slug: slug,
+					URL:  "/" + urlPath + "/" + slug + "/",
+				}
+				bySlug[slug] = term
+				res = append(res, term)
+			} else if !strings.EqualFold(term.Name, name) {
+				return nil, fmt.Errorf("terms %q and %q have the same slug: %s", term.Name, name, slug)
+			}
+
+			// don't list a post twice if it has duplicate terms
+			if n := len(term.Posts); n == 0 || term.Posts[n-1] != post {
+				term.Posts = append(term.Posts, post)
+			}
+		}
+	}
+
+	sort.Sort(termSlice(res))
+	return res, nil
+}
+
+func (b *Blog) loadTaxonomies() error {
+	b.taxonomies = nil
+	for _, taxonomy := range b.config.Taxonomies {
+		if taxonomy.Name == "" {
+			return errors.New("taxonomy name missing")
+		}
+
+		tax := *taxonomy
+		if tax.Path == "" {
+			tax.Path = tax.Name
+		}
+		// the path is relative to the root of the blog, without any slashes
+		// around it
+		tax.Path = strings.TrimPrefix(path.Clean("/"+tax.Path), "/")
+		if tax.Path == "" {
+			return fmt.Errorf("bad path for taxonomy %s: %s", tax.Name, taxonomy.Path)
+		}
+		for _, reserved := range reservedPaths {
+			if tax.Path == reserved || strings.HasPrefix(tax.Path, reserved+"/") {
+				return fmt.Errorf("path of taxonomy %s conflicts with /%s/: %s", tax.Name, reserved, taxonomy.Path)
+			}
+		}
+		if tax.Template == "" {
+			tax.Template = "taxonomy.html"
+		}
+		if tax.TermsTemplate == "" {
+			tax.TermsTemplate = "terms.html"
+		}
+		b.taxonomies = append(b.taxonomies, &tax)
+	}
+
+	// the tags feature is a shorthand for a predefined taxonomy
+	if b.hasFeature("tags") && b.getTaxonomy("tags") == nil {
+		b.taxonomies = append(b.taxonomies, &Taxonomy{
+			Name:          "tags",
+			Path:          "tag",
+			Template:      "tag.html",
+			TermsTemplate: "tags.html",
+		})
+	}
+
+	return nil
+}
+
+func (b *Blog) getTaxonomy(name string) *Taxonomy {
+	for _, taxonomy := range b.taxonomies {
+		if taxonomy.Name == name {
+			return taxonomy
+		}
+	}
+
+	return nil
+}
+
+// loadPostTerms collects the terms of every taxonomy from the front matter of
+// the given post.
+func (b *Blog) loadPostTerms(post *Post) error {
+	post.Taxonomies = map[string][]string{}
+	for _, taxonomy := range b.taxonomies {
+		var terms []string
+		switch v := post.Params[taxonomy.Name].(type) {
+		case nil:
+		case string:
+			terms = []string{v}
+		case []interface{}:
+			for _, term := range v {
+				terms = append(terms, fmt.Sprint(term))
+			}
+		default:
+			return fmt.Errorf("bad value for taxonomy %s: %v", taxonomy.Name, v)
+		}
+
+		post.Taxonomies[taxonomy.Name] = terms
+	}
+
+	return nil
+}
+
+func (b *Blog) renderTaxonomy(r *tmplRenderer, dir string, taxonomy *Taxonomy, posts []*Post) error {
+	taxDir := filepath.Join(dir, filepath.FromSlash(taxonomy.Path))
+	if err := os.MkdirAll(taxDir, 0777); err != nil {
+		return err
+	}
+
+	terms, err := collectTerms(posts, taxonomy.Path, func(post *Post) []string {
+		return post.Taxonomies[taxonomy.Name]
+	})
+	if err != nil {
+		return fmt.Errorf("taxonomy %s: %w", taxonomy.Name, err)
+	}
+
+	// generate a listing for every term
+	for _, term := range terms {
+		termDir := filepath.Join(taxDir, term.Slug)
+		if err := mkdir(termDir); err != nil {
+			return err
+		}
+
+		err := b.renderIndex(r, termDir, taxonomy.Template, term.Posts, func(info *IndexInfo) interface{} {
+			return &TermInfo{IndexInfo: info, Taxonomy: taxonomy, Term: term}
+		})
+		if err != nil {
+			return err
+		}
+
+		// generate a feed for every term
+		if b.hasFeeds() {
+			link, err := b.absURL(term.URL)
+			if err != nil {
+				return err
+			}
+
+			feed, err := b.newFeed(b.config.Title+" - "+term.Name, link, term.Posts)
+			if err != nil {
+				return err
+			}
+
+			if err = b.writeFeeds(termDir, feed); err != nil {
+				return err
+			}
+		}
+	}
+
+	// generate the term overview
+	info := TermsInfo{
+		PageInfo: &PageInfo{PageName: taxonomy.TermsTemplate, Blog: &b.config},
+		Taxonomy: taxonomy,
+		Terms:    terms,
+	}
+	return b.queuePage(r, filepath.Join(taxDir, "index.html"), taxonomy.TermsTemplate, &info)
+}
+
+// termURL returns the URL of the listing of the given term in the given
+// taxonomy.
+func (b *Blog) termURL(name string, term string) (string, error) {
+	taxonomy := b.getTaxonomy(name)
+	if taxonomy == nil {
+		return "", fmt.Errorf("taxonomy %s does not exist", name)
+	}
+
+	return "/" + taxonomy.Path + "/" + slugify(term) + "/", nil
+}
+
+func slugify(s string) string {
+	var sb strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteRune('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	return sb.String()
+}
@@ -7,10 +7,17 @@ import (
 	"path/filepath"
 )
 
-func (b *Blog) loadTemplatesDir(baseTemplate string, dir string) (map[string]*template.Template, error) {
+func (b *Blog) loadTemplatesDir(baseFilename string, baseTemplate string, dir string) (map[string]*template.Template, error) {
 	funcs := template.FuncMap{
-		"hasFeature": b.hasFeature,
-		"readFile":   b.readFile,
+		"hasFeature":  b.hasFeature,
+		"readFile":    b.readFile,
+		"slugify":     slugify,
+		"termURL":     b.termURL,
+		"localDate":   b.localDate,
+		"formatDate":  b.formatDate,
+		"openGraph":   b.openGraph,
+		"twitterCard": b.twitterCard,
+		"jsonLD":      b.jsonLD,
 		"inc": func(i int) int {
 			return i + 1
 		},
@@ -32,15 +39,15 @@ func (b *Blog) loadTemplatesDir(baseTemplate string, dir string) (map[string]*te
 		b.log("loading %s", filename)
 
 		// parse the child layout
-		childTmpl, err := template.New(file.Name()).Funcs(funcs).ParseFiles(filename)
+		tmpl, err := template.New(file.Name()).Funcs(funcs).ParseFiles(filename)
 		if err != nil {
-			return err
+			return newError(filename, templateError(templateFile(dir), err))
 		}
 
-		// and finally also parse the base layout
-		tmpl, err := childTmpl.Parse(baseTemplate)
-		if err != nil {
-			return err
+		// and finally also parse the base layout, under its own name so that
+		// errors in it can be told apart
+		if _, err = tmpl.New(filepath.Base(baseFilename)).Parse(baseTemplate); err != nil {
+			return newError(baseFilename, templateError(templateFile(dir), err))
 		}
 
 		templates[file.Name()] = tmpl
@@ -55,17 +62,18 @@ func (b *Blog) loadTemplatesDir(baseTemplate string, dir string) (map[string]*te
 }
 
 func (b *Blog) loadTemplates(dir string) error {
-	baseBytes, err := ioutil.ReadFile(filepath.Join(dir, "base.html"))
+	baseFilename := filepath.Join(dir, "base.html")
+	baseBytes, err := ioutil.ReadFile(baseFilename)
 	if err != nil {
 		return err
 	}
 
 	baseTemplate := string(baseBytes)
-	templates, err := b.loadTemplatesDir(baseTemplate, filepath.Join(dir, "components"))
+	templates, err := b.loadTemplatesDir(baseFilename, baseTemplate, filepath.Join(dir, "components"))
 	if err != nil {
 		return err
 	}
-	pageTemplates, err := b.loadTemplatesDir(baseTemplate, filepath.Join(dir, "pages"))
+	pageTemplates, err := b.loadTemplatesDir(baseFilename, baseTemplate, filepath.Join(dir, "pages"))
 	if err != nil {
 		return err
 	}
@@ -75,14 +83,36 @@ func (b *Blog) loadTemplates(dir string) error {
 	return nil
 }
 
-func (b *Blog) readFile(filename string) template.HTML {
+// templateFile returns a function that maps the names of the templates loaded
+// from the given directory to the files they were loaded from. The base layout
+// is in the parent directory.
+func templateFile(dir string) func(name string) string {
+	return func(name string) string {
+		if name == "base.html" {
+			return filepath.Join(filepath.Dir(dir), name)
+		}
+
+		return filepath.Join(dir, name)
+	}
+}
+
+// localDate returns the given date in the time zone of the blog.
+func (b *Blog) localDate(date PostDate) PostDate {
+	return date.In(b.location)
+}
+
+// formatDate formats the given date in the time zone of the blog.
+func (b *Blog) formatDate(layout string, date PostDate) string {
+	return date.In(b.location).Format(layout)
+}
+
+func (b *Blog) readFile(filename string) (template.HTML, error) {
 	filename = filepath.Join(b.dir, "theme", filename)
 
 	bytes, err := ioutil.ReadFile(filename)
 	if err != nil {
-		b.fatal("error reading %s: %s", filename, err)
-		return ""
+		return "", err
 	}
 
-	return template.HTML(bytes)
+	return template.HTML(bytes), nil
 }
@@ -39,6 +39,7 @@ type Theme struct {
 	Name   string   `yaml:"name"`
 	Static []string `yaml:"static"`
 	Style  Style    `yaml:"style"`
+	Card   Card     `yaml:"card"`
 	dir    string
 }
 
@@ -87,13 +88,13 @@ func (t *Theme) execSass(input string, w io.Writer) error {
 	return nil
 }
 
-func (t *Theme) Generate(dir string) error {
+func (t *Theme) Generate(out *output, dir string) error {
 	// copy the static files over
 	for _, path := range t.Static {
 		dst := filepath.Join(dir, path)
 		src := filepath.Join(t.dir, "static", path)
 
-		if err := copyFileOrDir(dst, src); err != nil {
+		if err := out.copyFileOrDir(dst, src); err != nil {
 			return err
 		}
 	}
@@ -103,16 +104,12 @@ func (t *Theme) Generate(dir string) error {
 	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
 		return err
 	}
-	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE, 0666)
-	if err != nil {
-		return err
-	}
-	defer dstFile.Close()
-	if err := t.execSass(filepath.Join(t.dir, t.Style.Input), dstFile); err != nil {
+	var buf bytes.Buffer
+	if err := t.execSass(filepath.Join(t.dir, t.Style.Input), &buf); err != nil {
 		return err
 	}
 
-	return nil
+	return out.writeFile(dst, buf.Bytes(), 0666)
 }
 
 func writeSyntaxCSS(w io.Writer, syntaxStyle *SyntaxStyle) error {
@@ -1,10 +1,15 @@
 package blog
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"sync"
 )
 
 func walkFiles(dir string, visit func(file os.FileInfo) error) error {
@@ -50,86 +55,147 @@ func mergeFiles(w io.Writer, files []string) error {
 	return nil
 }
 
-func copyFileOrDir(dst string, src string) error {
-	info, err := os.Stat(src)
-	if err != nil {
-		return err
-	}
+// siblingDir returns the path of a hidden directory next to the given one.
+func siblingDir(dir string, suffix string) string {
+	return filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+"."+suffix)
+}
 
-	if info.IsDir() {
-		if err = os.MkdirAll(dst, info.Mode()); err != nil {
+// linkDir recreates the directory tree at src in dst, hard linking all of the
+// files. Files are copied instead if they can't be linked.
+func linkDir(dst string, src string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
 			return err
 		}
-		err = copyDir(dst, src)
-	} else {
-		if err = os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
 			return err
 		}
-		err = copyFile(dst, src, info.Mode())
-	}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode())
+		}
 
-	return err
+		if err = os.Link(path, target); err != nil {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(target, data, info.Mode())
+		}
+
+		return nil
+	})
 }
 
-func copyFile(dst string, src string, mode os.FileMode) error {
-	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE, mode)
-	if err != nil {
+// swapDir replaces the directory dst with the directory src. Where supported,
+// the directories are exchanged atomically, leaving the previous contents of
+// dst in src. Otherwise dst is briefly missing, but it's restored if src can't
+// be moved into place.
+func swapDir(dst string, src string) error {
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		return os.Rename(src, dst)
+	}
+
+	exchanged, err := exchangeDirs(dst, src)
+	if err != nil || exchanged {
 		return err
 	}
-	defer dstFile.Close()
 
-	srcFile, err := os.Open(src)
-	if err != nil {
+	oldDir := siblingDir(dst, "old")
+	if err := os.RemoveAll(oldDir); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, oldDir); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if rerr := os.Rename(oldDir, dst); rerr != nil {
+			return fmt.Errorf("%w (previous output left in %s: %s)", err, oldDir, rerr)
+		}
 		return err
 	}
-	defer srcFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	return os.RemoveAll(oldDir)
 }
 
-func copyDir(dst string, src string) error {
-	files, err := ioutil.ReadDir(src)
+// replaceFile writes data to a temporary file and then renames it to the
+// given filename, so that readers never observe a partially written file.
+func replaceFile(filename string, data []byte, mode os.FileMode) error {
+	file, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(file.Name())
 
-	for _, file := range files {
-		dst := filepath.Join(dst, file.Name())
-		src := filepath.Join(src, file.Name())
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(file.Name(), mode); err != nil {
+		return err
+	}
 
-		if file.IsDir() {
-			if err = os.Mkdir(dst, file.Mode()); err != nil {
-				return err
-			}
+	return os.Rename(file.Name(), filename)
+}
 
-			if err = copyDir(dst, src); err != nil {
-				return err
-			}
-		} else {
-			if err = copyFile(dst, src, file.Mode()); err != nil {
-				return err
-			}
-		}
+// urlToFilename returns the filename a page with the given URL path is written
+// to in dir. Paths with a trailing slash are written as an index.html file.
+func urlToFilename(dir string, urlPath string) string {
+	filename := filepath.Join(dir, filepath.FromSlash(urlPath))
+	if strings.HasSuffix(urlPath, "/") {
+		filename = filepath.Join(filename, "index.html")
 	}
 
-	return nil
+	return filename
 }
 
-func clearDir(dir string) error {
-	file, err := os.Open(dir)
+// resolveURL joins the given path onto the path of the given base URL.
+func resolveURL(base string, p string) (string, error) {
+	u, err := url.Parse(base)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer file.Close()
 
-	names, err := file.Readdirnames(-1)
-	if err != nil {
-		return err
+	trailingSlash := strings.HasSuffix(p, "/")
+	u.Path = path.Join(u.Path, p)
+	if trailingSlash && !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	return u.String(), nil
+}
+
+// parallel calls fn for every index in [0, n) using at most jobs goroutines.
+// If any of the calls fail, the error of the call with the lowest index is
+// returned, regardless of the order in which the calls finished.
+func parallel(jobs int, n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	for j := 0; j < min(jobs, n); j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indices <- i
 	}
+	close(indices)
+	wg.Wait()
 
-	for _, name := range names {
-		err = os.RemoveAll(filepath.Join(dir, name))
+	for _, err := range errs {
 		if err != nil {
 			return err
 		}
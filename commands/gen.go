@@ -1,9 +1,11 @@
 package commands
 
 import (
+	"fmt"
 	logger "log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"runtime/pprof"
 	"time"
 
@@ -12,11 +14,18 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const (
+	cacheDirname = ".blogen-cache"
+)
+
 type genFlags struct {
 	OutputDir      string
 	IncludeDrafts  bool
+	IncludeFuture  bool
 	CPUProfileFile string
 	VersionInfo    string
+	Jobs           int
+	NoCache        bool
 }
 
 var (
@@ -34,8 +43,11 @@ func init() {
 	RootCmd.AddCommand(genCmd)
 	genCmd.Flags().StringVarP(&genCmdFlags.OutputDir, "output", "o", "", "The output directory")
 	genCmd.Flags().BoolVarP(&genCmdFlags.IncludeDrafts, "include-drafts", "", false, "Include draft posts")
+	genCmd.Flags().BoolVarP(&genCmdFlags.IncludeFuture, "include-future", "", false, "Include posts with a date in the future")
 	genCmd.Flags().StringVarP(&genCmdFlags.CPUProfileFile, "cpu-profile", "", "", "The location to output a CPU profile recording to")
 	genCmd.Flags().StringVarP(&genCmdFlags.VersionInfo, "version-info", "", "", "Version info to pass to blog templates (i.e. git hash)")
+	genCmd.Flags().IntVarP(&genCmdFlags.Jobs, "jobs", "j", runtime.NumCPU(), "The number of pages to render concurrently")
+	genCmd.Flags().BoolVarP(&genCmdFlags.NoCache, "no-cache", "", false, "Don't use the build cache")
 }
 
 func startGen(cmd *cobra.Command, args []string) {
@@ -43,14 +55,16 @@ func startGen(cmd *cobra.Command, args []string) {
 		genCmdFlags.OutputDir = filepath.Join(rootCmdFlags.Dir, "public")
 	}
 
-	generateBlog(rootCmdFlags.Dir, &genCmdFlags)
+	if err := generateBlog(rootCmdFlags.Dir, &genCmdFlags); err != nil {
+		log.Fatalf("%s", err)
+	}
 }
 
-func generateBlog(inDir string, flags *genFlags) {
+func generateBlog(inDir string, flags *genFlags) error {
 	if genCmdFlags.CPUProfileFile != "" {
 		file, err := os.Create(genCmdFlags.CPUProfileFile)
 		if err != nil {
-			log.Fatalf("pprof file creation error: %s", err)
+			return fmt.Errorf("pprof file creation error: %w", err)
 		}
 		defer file.Close()
 
@@ -63,10 +77,15 @@ func generateBlog(inDir string, flags *genFlags) {
 
 	var err error
 	if cfg, err = config.Load(inDir); err != nil {
-		log.Fatalf("config error: %s", err)
+		return fmt.Errorf("config error: %w", err)
 	}
 	cfg.Blog.VersionInfo = flags.VersionInfo
 	cfg.Blog.ExcludeDrafts = !flags.IncludeDrafts
+	cfg.Blog.ExcludeFuture = !flags.IncludeFuture
+	cfg.Blog.Jobs = flags.Jobs
+	if !flags.NoCache {
+		cfg.Blog.CacheDir = filepath.Join(inDir, cacheDirname)
+	}
 
 	var logger *logger.Logger
 	if rootCmdFlags.Verbose {
@@ -75,12 +94,13 @@ func generateBlog(inDir string, flags *genFlags) {
 
 	blog, err := blog.New(cfg.Blog, inDir, logger)
 	if err != nil {
-		log.Fatalf("blog init error: %s", err)
+		return fmt.Errorf("blog init error: %w", err)
 	}
 
 	if err = blog.Generate(flags.OutputDir); err != nil {
-		log.Fatalf("error generating blog: %s", err)
+		return fmt.Errorf("error generating blog: %w", err)
 	}
 
 	log.Printf("done! %dms", time.Since(start).Nanoseconds()/int64(time.Millisecond))
+	return nil
 }
@@ -6,17 +6,25 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/alexbakker/blogen/server"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
 )
 
+const (
+	watchDebounce = 100 * time.Millisecond
+)
+
 type serveFlags struct {
 	Addr          string
 	OutputDir     string
 	ExcludeDrafts bool
+	IncludeFuture bool
 	VersionInfo   string
+	LiveReload    bool
 }
 
 var (
@@ -33,7 +41,9 @@ func init() {
 	serveCmd.Flags().StringVarP(&serveCmdFlags.Addr, "addr", "a", "127.0.0.1:8080", "The TCP port to listen on")
 	serveCmd.Flags().StringVarP(&serveCmdFlags.OutputDir, "output", "o", "", "The output directory")
 	serveCmd.Flags().BoolVarP(&serveCmdFlags.ExcludeDrafts, "exclude-drafts", "", false, "Exclude draft posts")
+	serveCmd.Flags().BoolVarP(&serveCmdFlags.IncludeFuture, "include-future", "", false, "Include posts with a date in the future")
 	serveCmd.Flags().StringVarP(&serveCmdFlags.VersionInfo, "version-info", "", "dev", "Version info to pass to blog templates (i.e. git hash)")
+	serveCmd.Flags().BoolVarP(&serveCmdFlags.LiveReload, "live-reload", "", true, "Reload the browser when the blog is regenerated")
 }
 
 func startServe(cmd *cobra.Command, args []string) {
@@ -46,14 +56,13 @@ func startServe(cmd *cobra.Command, args []string) {
 		defer os.RemoveAll(serveCmdFlags.OutputDir)
 	}
 
-	generateBlog(rootCmdFlags.Dir, &genFlags{
-		OutputDir:     serveCmdFlags.OutputDir,
-		IncludeDrafts: !serveCmdFlags.ExcludeDrafts,
-		VersionInfo:   serveCmdFlags.VersionInfo,
-	})
+	server := server.New(server.Config{
+		Addr:       serveCmdFlags.Addr,
+		LiveReload: serveCmdFlags.LiveReload,
+	}, serveCmdFlags.OutputDir)
+	rebuildBlog(server)
 
 	// start HTTP server
-	server := server.New(server.Config{Addr: serveCmdFlags.Addr}, serveCmdFlags.OutputDir)
 	go func() {
 		log.Printf("starting http server on %s", serveCmdFlags.Addr)
 		log.Fatal(http.ListenAndServe(serveCmdFlags.Addr, server))
@@ -61,11 +70,8 @@ func startServe(cmd *cobra.Command, args []string) {
 
 	// watch for changes to the source directory
 	go func() {
-		for {
-			if err := watchBlog(); err != nil {
-				log.Printf("watcher error: %s", err)
-				break
-			}
+		if err := watchBlog(server); err != nil {
+			log.Printf("watcher error: %s", err)
 		}
 	}()
 
@@ -75,51 +81,118 @@ func startServe(cmd *cobra.Command, args []string) {
 	<-sig
 }
 
-func watchBlog() error {
+// rebuildBlog regenerates the blog and reports the result to the browser. The
+// last good output keeps being served if generation fails.
+func rebuildBlog(srv *server.Server) {
+	err := generateBlog(rootCmdFlags.Dir, &genFlags{
+		OutputDir:     serveCmdFlags.OutputDir,
+		IncludeDrafts: !serveCmdFlags.ExcludeDrafts,
+		IncludeFuture: serveCmdFlags.IncludeFuture,
+		VersionInfo:   serveCmdFlags.VersionInfo,
+	})
+	if err != nil {
+		log.Printf("%s", err)
+	}
+
+	srv.SetError(err)
+	srv.Reload()
+}
+
+// watchBlog watches the source directory for changes and rebuilds the blog
+// once a burst of changes has settled down.
+func watchBlog(srv *server.Server) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
 	defer watcher.Close()
 
-	done := make(chan error)
-	go func() {
-		for {
-			select {
-			case _, ok := <-watcher.Events:
-				if ok {
-					generateBlog(rootCmdFlags.Dir, &genFlags{
-						OutputDir:     serveCmdFlags.OutputDir,
-						IncludeDrafts: !serveCmdFlags.ExcludeDrafts,
-						VersionInfo:   "dev",
-					})
-				}
-				done <- nil
-				return
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					done <- nil
-					return
+	if err = watchDir(watcher, rootCmdFlags.Dir); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ignoreWatchPath(event.Name) {
+				continue
+			}
+
+			// start watching newly created directories
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err = watchDir(watcher, event.Name); err != nil {
+						log.Printf("watcher error: %s", err)
+					}
 				}
-				log.Printf("watcher error: %s", err)
 			}
-		}
-	}()
 
-	// recursively add directories to the watcher
-	err = filepath.Walk(rootCmdFlags.Dir, func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() {
-			// TODO: ignore git directory
-			if err = watcher.Add(path); err != nil {
-				return err
+			timer.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
 			}
+			log.Printf("watcher error: %s", err)
+		case <-timer.C:
+			rebuildBlog(srv)
+		}
+	}
+}
+
+// watchDir recursively adds the given directory to the watcher.
+func watchDir(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if ignoreWatchPath(path) {
+			return filepath.SkipDir
 		}
 
-		return nil
+		return watcher.Add(path)
 	})
+}
+
+// ignoreWatchPath reports whether changes to the given path should not trigger
+// a rebuild. This covers hidden files and directories like .git and the
+// staging directory of the output, the swap and backup files of editors and
+// the output directory itself.
+func ignoreWatchPath(path string) bool {
+	if absPath, err := filepath.Abs(path); err == nil {
+		if absOutput, err := filepath.Abs(serveCmdFlags.OutputDir); err == nil {
+			if absPath == absOutput || strings.HasPrefix(absPath, absOutput+string(filepath.Separator)) {
+				return true
+			}
+		}
+	}
+
+	rel, err := filepath.Rel(rootCmdFlags.Dir, path)
 	if err != nil {
-		return err
+		return false
+	}
+
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if len(name) > 1 && strings.HasPrefix(name, ".") && name != ".." {
+			return true
+		}
+	}
+
+	name := filepath.Base(path)
+	for _, suffix := range []string{"~", ".swp", ".swx", ".swo", ".tmp"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
 	}
 
-	return <-done
+	// emacs auto-save files and the file vim creates to check write access
+	return (strings.HasPrefix(name, "#") && strings.HasSuffix(name, "#")) || name == "4913"
 }
@@ -1,5 +1,6 @@
 package server
 
 type Config struct {
-	Addr string
+	Addr       string
+	LiveReload bool
 }
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	errorPage = "<!DOCTYPE html><html><head><title>Build error</title></head><body></body></html>"
+)
+
+// injectWriter buffers HTML responses so that a snippet can be injected into
+// them. Other responses are passed through as-is, unless notFound is set, in
+// which case 404 responses are replaced with an HTML page containing the
+// snippet.
+type injectWriter struct {
+	http.ResponseWriter
+	snippet     []byte
+	notFound    bool
+	buf         bytes.Buffer
+	status      int
+	inject      bool
+	wroteHeader bool
+}
+
+func (w *injectWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	isHTML := strings.HasPrefix(w.Header().Get("Content-Type"), "text/html")
+	if (status == http.StatusOK && isHTML) || (status == http.StatusNotFound && w.notFound) {
+		w.status = status
+		w.inject = true
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *injectWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.inject {
+		return w.buf.Write(p)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// finish injects the snippet into a buffered HTML response and writes it out.
+func (w *injectWriter) finish() error {
+	if !w.inject {
+		return nil
+	}
+
+	body := w.buf.Bytes()
+	if w.status == http.StatusNotFound {
+		body = []byte(errorPage)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+
+	if i := bytes.LastIndex(body, []byte("</body>")); i != -1 {
+		body = append(body[:i:i], append(w.snippet, body[i:]...)...)
+	} else {
+		body = append(body, w.snippet...)
+	}
+
+	// the snippet changes between builds, so the response must not be cached
+	w.Header().Del("X-Content-Type-Options")
+	w.Header().Del("Last-Modified")
+	w.Header().Del("ETag")
+	w.Header().Del("Accept-Ranges")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(body)
+	return err
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"fmt"
+	"html"
+)
+
+const (
+	overlayTemplate = `<div id="blogen-error" style="position: fixed; inset: 0; z-index: 2147483647; overflow: auto; padding: 2em; background: rgba(0, 0, 0, 0.9); color: #ff6b6b; font: 14px/1.5 monospace;">` +
+		`<h2 style="margin-top: 0; color: #fff;">Build failed</h2><pre style="white-space: pre-wrap;">%s</pre></div>`
+)
+
+// SetError sets the error of the last build. Until it is cleared by passing
+// nil, an overlay with the error is shown on top of every HTML page.
+func (s *Server) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+func (s *Server) getError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+func renderOverlay(err error) string {
+	return fmt.Sprintf(overlayTemplate, html.EscapeString(err.Error()))
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const (
+	reloadPath   = "/_blogen/reload"
+	reloadScript = `<script>new EventSource("` + reloadPath + `").addEventListener("reload", function() { location.reload(); });</script>`
+)
+
+// Reload notifies all connected browsers that they should reload the page.
+func (s *Server) Reload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for client := range s.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Server) subscribe() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client := make(chan struct{}, 1)
+	s.clients[client] = struct{}{}
+	return client
+}
+
+func (s *Server) unsubscribe(client chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.clients, client)
+}
+
+// handleReload streams reload events to the browser using Server-Sent Events.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client := s.subscribe()
+	defer s.unsubscribe(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client:
+			fmt.Fprint(w, "event: reload\ndata: \n\n")
+			flusher.Flush()
+		}
+	}
+}
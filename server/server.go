@@ -2,23 +2,56 @@ package server
 
 import (
 	"net/http"
+	"sync"
 )
 
 type Server struct {
-	mux    *http.ServeMux
-	config Config
+	mux     *http.ServeMux
+	config  Config
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+	err     error
 }
 
 func New(config Config, dir string) *Server {
-	mux := http.NewServeMux()
-	mux.Handle("/", http.FileServer(http.Dir(dir)))
+	s := &Server{
+		mux:     http.NewServeMux(),
+		config:  config,
+		clients: map[chan struct{}]struct{}{},
+	}
 
-	return &Server{
-		mux:    mux,
-		config: config,
+	s.mux.Handle("/", http.FileServer(http.Dir(dir)))
+	if config.LiveReload {
+		s.mux.HandleFunc(reloadPath, s.handleReload)
 	}
+
+	return s
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	err := s.getError()
+	if (!s.config.LiveReload && err == nil) || r.Method != http.MethodGet || r.URL.Path == reloadPath {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+
+	// inject the error overlay and the live reload script into HTML responses
+	var snippet string
+	if err != nil {
+		snippet += renderOverlay(err)
+	}
+	if s.config.LiveReload {
+		snippet += reloadScript
+	}
+
+	// conditional and range requests would bypass the injection, and return a
+	// page that was cached with or without the snippet
+	r = r.Clone(r.Context())
+	for _, header := range []string{"If-Modified-Since", "If-None-Match", "If-Range", "Range"} {
+		r.Header.Del(header)
+	}
+
+	iw := &injectWriter{ResponseWriter: w, snippet: []byte(snippet), notFound: err != nil}
+	s.mux.ServeHTTP(iw, r)
+	iw.finish()
 }